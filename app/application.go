@@ -13,6 +13,7 @@ import (
 	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
 	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/meta/v1"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	ingresscontroller "github.com/rodrigoafernandes/efk-cluster/ingress-controller"
 )
 
 type App interface {
@@ -48,6 +49,11 @@ func NewApp(context *pulumi.Context, provider *kubernetes.Provider, cfg *config.
 	}
 }
 
+// Routes are the Ingress rules of the languages API.
+func Routes() []ingresscontroller.Route {
+	return []ingresscontroller.Route{{Ingress: "languages-api", Host: ingresscontroller.LoadBalancerHost, Path: "/alura-languages(/|$)(.*)"}}
+}
+
 func (a resource) CreateResources(hostname pulumi.StringOutput, dependsOnResources ...pulumi.Resource) error {
 	redisService := dependsOnResources[0].(*corev1.Service)
 	mongodbService := dependsOnResources[1].(*corev1.Service)
@@ -62,7 +68,7 @@ func (a resource) CreateResources(hostname pulumi.StringOutput, dependsOnResourc
 	}, pulumi.Provider(a.provider), pulumi.DependsOn(dependsOnResources))
 
 	if err != nil {
-		return err
+		return fmt.Errorf("creating namespace alura: %w", err)
 	}
 
 	ghRegistrySecret, err := corev1.NewSecret(a.ctx, "gh-registry-secrets", &corev1.SecretArgs{
@@ -77,7 +83,7 @@ func (a resource) CreateResources(hostname pulumi.StringOutput, dependsOnResourc
 	}, pulumi.Provider(a.provider), pulumi.Parent(namespace))
 
 	if err != nil {
-		return err
+		return fmt.Errorf("creating secret gh-registry-secrets: %w", err)
 	}
 
 	mongoDBURI := pulumi.Sprintf("mongodb://%s.%s.svc.cluster.local:%d", mongodbService.Metadata.Name().Elem(), mongodbService.Metadata.Namespace().Elem(), mongodbService.Spec.Ports().Index(pulumi.Int(0)).Port())
@@ -97,7 +103,7 @@ func (a resource) CreateResources(hostname pulumi.StringOutput, dependsOnResourc
 	}, pulumi.Provider(a.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{ghRegistrySecret}))
 
 	if err != nil {
-		return err
+		return fmt.Errorf("creating secret languages-api-secrets: %w", err)
 	}
 
 	appLabels := pulumi.StringMap{
@@ -182,7 +188,7 @@ func (a resource) CreateResources(hostname pulumi.StringOutput, dependsOnResourc
 	}, pulumi.Provider(a.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{secret}))
 
 	if err != nil {
-		return err
+		return fmt.Errorf("creating deployment languages-api: %w", err)
 	}
 
 	service, err := corev1.NewService(a.ctx, "languages-api", &corev1.ServiceArgs{
@@ -205,7 +211,7 @@ func (a resource) CreateResources(hostname pulumi.StringOutput, dependsOnResourc
 	}, pulumi.Provider(a.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{deployment}))
 
 	if err != nil {
-		return err
+		return fmt.Errorf("creating service languages-api: %w", err)
 	}
 
 	_, err = networkingv1.NewIngress(a.ctx, "languages-api", &networkingv1.IngressArgs{
@@ -243,7 +249,7 @@ func (a resource) CreateResources(hostname pulumi.StringOutput, dependsOnResourc
 	}, pulumi.Provider(a.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{service}))
 
 	if err != nil {
-		return err
+		return fmt.Errorf("creating ingress languages-api: %w", err)
 	}
 
 	_, err = autoscalingv2.NewHorizontalPodAutoscaler(a.ctx, "languages-api", &autoscalingv2.HorizontalPodAutoscalerArgs{
@@ -275,7 +281,7 @@ func (a resource) CreateResources(hostname pulumi.StringOutput, dependsOnResourc
 	}, pulumi.Provider(a.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{deployment}))
 
 	if err != nil {
-		return err
+		return fmt.Errorf("creating horizontal pod autoscaler languages-api: %w", err)
 	}
 
 	return nil
@@ -6,6 +6,7 @@ import (
 	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes"
 	"github.com/pulumi/pulumi-linode/sdk/v3/go/linode"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
 	"io/fs"
 	"io/ioutil"
 )
@@ -16,6 +17,7 @@ type K8sCluster interface {
 
 type cluster struct {
 	ctx *pulumi.Context
+	cfg *config.Config
 }
 
 func (c cluster) Create() (*kubernetes.Provider, error) {
@@ -25,7 +27,7 @@ func (c cluster) Create() (*kubernetes.Provider, error) {
 		Pools: linode.LkeClusterPoolArray{
 			&linode.LkeClusterPoolArgs{
 				Count: pulumi.Int(3),
-				Type:  pulumi.String("g6-dedicated-4"),
+				Type:  pulumi.String(PoolNodeType(c.cfg)),
 			},
 		},
 		Region: pulumi.String("us-central"),
@@ -35,14 +37,14 @@ func (c cluster) Create() (*kubernetes.Provider, error) {
 		},
 	})
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("creating lke cluster efk-cluster: %w", err)
 	}
 	provider, err := kubernetes.NewProvider(c.ctx, "k8s_provider", &kubernetes.ProviderArgs{
 		Kubeconfig:            c.createKubeconfig(k8sCluster.Kubeconfig),
 		EnableServerSideApply: pulumi.Bool(true),
 	})
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("creating provider k8s_provider: %w", err)
 	}
 	c.ctx.Export("kubeconfig", c.createKubeconfig(k8sCluster.Kubeconfig).ApplyT(func(kcfg string) string {
 		err = ioutil.WriteFile("efk-cluster-kubeconfig.yaml", []byte(kcfg), fs.FileMode(0600))
@@ -57,8 +59,8 @@ func (c cluster) Create() (*kubernetes.Provider, error) {
 	return provider, nil
 }
 
-func NewCluster(ctx *pulumi.Context) K8sCluster {
-	return cluster{ctx: ctx}
+func NewCluster(ctx *pulumi.Context, cfg *config.Config) K8sCluster {
+	return cluster{ctx: ctx, cfg: cfg}
 }
 
 func (c cluster) createKubeconfig(kubeconfig pulumi.StringOutput) pulumi.StringOutput {
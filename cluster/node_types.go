@@ -0,0 +1,51 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+const (
+	defaultNodeType = "g6-dedicated-4"
+	nodeTypesFile   = "node-types.json"
+)
+
+// NodeType is a Linode plan as listed by the /linode/types API, saved in nodeTypesFile.
+type NodeType struct {
+	ID     string `json:"id"`
+	Label  string `json:"label"`
+	Memory int    `json:"memory"`
+	VCPUs  int    `json:"vcpus"`
+}
+
+// PoolNodeType is the plan of the nodes of the cluster pool, "lke_node_type" or
+// g6-dedicated-4.
+func PoolNodeType(cfg *config.Config) string {
+	if nodeType := cfg.Get("lke_node_type"); nodeType != "" {
+		return nodeType
+	}
+	return defaultNodeType
+}
+
+// LookupNodeType reads the memory, in MB, and vCPUs of the plan id from nodeTypesFile.
+func LookupNodeType(id string) (NodeType, error) {
+	content, err := os.ReadFile(nodeTypesFile)
+	if err != nil {
+		return NodeType{}, err
+	}
+	var types struct {
+		Data []NodeType `json:"data"`
+	}
+	if err = json.Unmarshal(content, &types); err != nil {
+		return NodeType{}, fmt.Errorf("%s: %w", nodeTypesFile, err)
+	}
+	for _, nodeType := range types.Data {
+		if nodeType.ID == id {
+			return nodeType, nil
+		}
+	}
+	return NodeType{}, fmt.Errorf("node type %s is not listed in %s", id, nodeTypesFile)
+}
@@ -0,0 +1,39 @@
+// Command efkctl bundles the operational tasks of the logging stack that run outside
+// of `pulumi up`.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+var commands = map[string]func(args []string) error{
+	"parse-check": parseCheck,
+	"replay":      replay,
+	"restore":     restore,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	if err := command(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "efkctl %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: efkctl <command> [flags]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "commands:")
+	fmt.Fprintln(os.Stderr, "  parse-check  run the Fluentd parse patterns against fixture log lines")
+	fmt.Fprintln(os.Stderr, "  replay       bulk-index archived or dead-lettered log chunks into Elasticsearch")
+	fmt.Fprintln(os.Stderr, "  restore      restore indices from the Elasticsearch snapshot repository")
+}
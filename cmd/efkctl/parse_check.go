@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	fluentdlogging "github.com/rodrigoafernandes/efk-cluster/fluentd_logging"
+)
+
+// parseCheck reports, for every fixture line, which pattern accepted it, which fields
+// came out or why the pipeline drops it, and fails when a line would be dropped by the
+// tail source.
+func parseCheck(args []string) error {
+	flags := flag.NewFlagSet("parse-check", flag.ExitOnError)
+	fixtures := flags.String("fixtures", "fluentd_logging/testdata", "directory with *.log fixture files")
+	format := flags.String("format", string(fluentdlogging.Auto), "container log format: cri, docker-json or auto")
+	values := flags.Bool("values", false, "print the extracted values instead of the field names")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	results, err := fluentdlogging.ParseFixtures(*fixtures, fluentdlogging.LogFormat(*format))
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return fmt.Errorf("no fixture lines found in %s", *fixtures)
+	}
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(out, "FIXTURE\tTAIL\tLOG\tFIELDS\tDROPPED")
+	unmatched, dropped := 0, 0
+	for _, result := range results {
+		if !result.Matched() {
+			unmatched++
+		}
+		if result.Dropped() != "" {
+			dropped++
+		}
+		fmt.Fprintf(out, "%s:%d\t%s\t%s\t%s\t%s\n",
+			result.Fixture, result.Line, orDash(result.TailPattern), orDash(result.LogPattern), formatFields(result.Fields, *values), orDash(result.Dropped()))
+	}
+	if err = out.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("%d of %d lines would be dropped\n", dropped, len(results))
+	if unmatched > 0 {
+		return fmt.Errorf("%d of %d lines matched no tail pattern and would be dropped", unmatched, len(results))
+	}
+	return nil
+}
+
+func formatFields(fields map[string]interface{}, values bool) string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if values {
+		for i, name := range names {
+			names[i] = fmt.Sprintf("%s=%q", name, fmt.Sprint(fields[name]))
+		}
+		return orDash(strings.Join(names, " "))
+	}
+	return orDash(strings.Join(names, ","))
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	logreplay "github.com/rodrigoafernandes/efk-cluster/log_replay"
+)
+
+// replay re-indexes archived or dead-lettered chunks into Elasticsearch. The cluster is
+// usually reached through `kubectl port-forward svc/elasticsearch 9200 -n efk-logging`.
+func replay(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	url := flags.String("url", "http://localhost:9200", "Elasticsearch URL")
+	user := flags.String("user", os.Getenv("ELASTICSEARCH_USER"), "Elasticsearch user, the password is read from ELASTICSEARCH_PASSWORD")
+	index := flags.String("index", "", "index the documents are written to, e.g. apps-log")
+	batchSize := flags.Int("batch-size", 500, "documents per bulk request")
+	rate := flags.Float64("rate", 1000, "maximum documents per second, 0 disables the limit")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: efkctl replay -index <index> [flags] <file or directory>...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	files, err := chunkFiles(flags.Args())
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		flags.Usage()
+		return fmt.Errorf("no chunk files given")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	stats, err := logreplay.Replay(ctx, logreplay.Options{
+		URL:       *url,
+		User:      *user,
+		Password:  os.Getenv("ELASTICSEARCH_PASSWORD"),
+		Index:     *index,
+		BatchSize: *batchSize,
+		Rate:      *rate,
+	}, files)
+	fmt.Printf("read %d, created %d, already indexed %d, failed %d\n", stats.Read, stats.Created, stats.Duplicates, stats.Failed)
+	if err != nil {
+		return err
+	}
+	if stats.Failed > 0 {
+		return fmt.Errorf("%d documents were rejected", stats.Failed)
+	}
+	return nil
+}
+
+// chunkFiles expands directories into the chunk files they contain.
+func chunkFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
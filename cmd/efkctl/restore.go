@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	es "github.com/rodrigoafernandes/efk-cluster/elasticsearch_logging"
+	snapshotrestore "github.com/rodrigoafernandes/efk-cluster/snapshot_restore"
+)
+
+// restore brings indices back from the snapshots taken by the SLM policy. The cluster is
+// usually reached through `kubectl port-forward svc/elasticsearch 9200 -n efk-logging`.
+//
+//	efkctl restore -list
+//	efkctl restore -indices 'apps-log-2023.01.*'
+//	efkctl restore -snapshot scheduled-2023.01.20-abc -indices apps-log-2023.01.19 -rename-prefix ''
+//
+// Indices are restored as restored-<name> by default, next to the live ones. Restoring
+// under the original name needs the existing index to be closed or deleted first.
+func restore(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	url := flags.String("url", "http://localhost:9200", "Elasticsearch URL")
+	user := flags.String("user", os.Getenv("ELASTICSEARCH_USER"), "Elasticsearch user, the password is read from ELASTICSEARCH_PASSWORD")
+	repository := flags.String("repository", es.SnapshotRepository, "snapshot repository")
+	list := flags.Bool("list", false, "list the snapshots of the repository and exit")
+	snapshot := flags.String("snapshot", "latest", "snapshot to restore, latest picks the most recent successful one")
+	indices := flags.String("indices", "*,-.*", "comma separated index names or patterns to restore, the default skips system indices")
+	renamePrefix := flags.String("rename-prefix", "restored-", "prefix added to the restored index names, empty keeps the names")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: efkctl restore [flags]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	opts := snapshotrestore.Options{
+		URL:        *url,
+		User:       *user,
+		Password:   os.Getenv("ELASTICSEARCH_PASSWORD"),
+		Repository: *repository,
+	}
+	if *list {
+		snapshots, err := snapshotrestore.List(ctx, opts)
+		if err != nil {
+			return err
+		}
+		for _, s := range snapshots {
+			fmt.Printf("%s\t%s\t%s\t%d indices\n", s.Name, s.State, s.StartTime.Format("2006-01-02 15:04:05"), len(s.Indices))
+		}
+		return nil
+	}
+	if *snapshot == "latest" {
+		latest, err := snapshotrestore.Latest(ctx, opts)
+		if err != nil {
+			return err
+		}
+		*snapshot = latest.Name
+	}
+	fmt.Printf("restoring %s from %s\n", *indices, *snapshot)
+	return snapshotrestore.Restore(ctx, opts, snapshotrestore.Request{
+		Snapshot:     *snapshot,
+		Indices:      *indices,
+		RenamePrefix: *renamePrefix,
+	})
+}
@@ -0,0 +1,165 @@
+package elasticsearchlogging
+
+import (
+	"fmt"
+
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/meta/v1"
+	rbac "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/rbac/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	// awarenessAttribute is the node attribute shards are spread over. Its value is the
+	// TopologyKey label of the Kubernetes node a data node runs on.
+	awarenessAttribute = "zone"
+	zoneServiceAccount = "elasticsearch-zone-sa"
+	zoneDir            = "/opt/bitnami/elasticsearch/zone"
+)
+
+// The downward API does not expose the labels of a node, so an init container reads the
+// TopologyKey label of its node from the API server and leaves it in zoneDir. The label
+// and the node are passed through the environment, so no value is parsed by the shell.
+const zoneScript = `set -e
+sa=/var/run/secrets/kubernetes.io/serviceaccount
+node=$(curl -sSf --cacert "$sa/ca.crt" -H "Authorization: Bearer $(cat "$sa/token")" "https://kubernetes.default.svc/api/v1/nodes/$NODE_NAME")
+zone=$(echo "$node" | sed -n "s|.*\"$TOPOLOGY_KEY\": *\"\([^\"]*\)\".*|\1|p")
+if [ -z "$zone" ]; then
+  echo "node $NODE_NAME has no $TOPOLOGY_KEY label"
+  exit 1
+fi
+echo "$zone" > ` + zoneDir + `/zone`
+
+// The data nodes export the zone before the image entrypoint renders elasticsearch.yml,
+// which reads it as ${K8S_NODE_ZONE}.
+const zoneEntrypoint = `export K8S_NODE_ZONE="$(cat ` + zoneDir + `/zone)"
+exec /opt/bitnami/scripts/elasticsearch/entrypoint.sh /opt/bitnami/scripts/elasticsearch/run.sh`
+
+// awarenessValues are the chart values of the data nodes that set their zone.
+func (t topology) awarenessValues() pulumi.Map {
+	return pulumi.Map{
+		"serviceAccount": pulumi.Map{
+			"create":                       pulumi.Bool(false),
+			"name":                         pulumi.String(zoneServiceAccount),
+			"automountServiceAccountToken": pulumi.Bool(true),
+		},
+		"initContainers": pulumi.Array{
+			pulumi.Map{
+				"name":  pulumi.String("node-zone"),
+				"image": pulumi.String("docker.io/curlimages/curl:7.87.0"),
+				"command": pulumi.StringArray{
+					pulumi.String("sh"),
+					pulumi.String("-c"),
+					pulumi.String(zoneScript),
+				},
+				"env": pulumi.Array{
+					pulumi.Map{
+						"name": pulumi.String("NODE_NAME"),
+						"valueFrom": pulumi.Map{
+							"fieldRef": pulumi.Map{
+								"fieldPath": pulumi.String("spec.nodeName"),
+							},
+						},
+					},
+					pulumi.Map{
+						"name":  pulumi.String("TOPOLOGY_KEY"),
+						"value": pulumi.String(t.TopologyKey),
+					},
+				},
+				"volumeMounts": pulumi.Array{
+					pulumi.Map{
+						"name":      pulumi.String("node-zone"),
+						"mountPath": pulumi.String(zoneDir),
+					},
+				},
+			},
+		},
+		"extraVolumes": pulumi.Array{
+			pulumi.Map{
+				"name":     pulumi.String("node-zone"),
+				"emptyDir": pulumi.Map{},
+			},
+		},
+		"extraVolumeMounts": pulumi.Array{
+			pulumi.Map{
+				"name":      pulumi.String("node-zone"),
+				"mountPath": pulumi.String(zoneDir),
+			},
+		},
+		"command": pulumi.StringArray{
+			pulumi.String("/bin/bash"),
+			pulumi.String("-ec"),
+		},
+		"args": pulumi.StringArray{
+			pulumi.String(zoneEntrypoint),
+		},
+	}
+}
+
+// settings are the elasticsearch.yml entries of the awareness attribute, so a primary and
+// its replica are kept on distinct values of TopologyKey.
+func (t topology) settings() pulumi.Map {
+	if !t.Awareness {
+		return pulumi.Map{}
+	}
+	return pulumi.Map{
+		"node.attr." + awarenessAttribute:                 pulumi.String("${K8S_NODE_ZONE}"),
+		"cluster.routing.allocation.awareness.attributes": pulumi.String(awarenessAttribute),
+	}
+}
+
+// createZoneReader lets the data nodes read the labels of the node they run on.
+func (e resource) createZoneReader(namespace *corev1.Namespace) (pulumi.Resource, error) {
+	clusterRole, err := rbac.NewClusterRole(e.ctx, "elasticsearch-zone-cr", &rbac.ClusterRoleArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name: pulumi.String("elasticsearch-zone-cr"),
+		},
+		Rules: &rbac.PolicyRuleArray{
+			&rbac.PolicyRuleArgs{
+				ApiGroups: pulumi.StringArray{
+					pulumi.String(""),
+				},
+				Resources: pulumi.StringArray{
+					pulumi.String("nodes"),
+				},
+				Verbs: pulumi.StringArray{
+					pulumi.String("get"),
+				},
+			},
+		},
+	}, pulumi.Provider(e.provider), pulumi.Parent(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("creating cluster role elasticsearch-zone-cr: %w", err)
+	}
+	serviceAccount, err := corev1.NewServiceAccount(e.ctx, zoneServiceAccount, &corev1.ServiceAccountArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(zoneServiceAccount),
+			Namespace: namespace.Metadata.Name(),
+		},
+		AutomountServiceAccountToken: pulumi.Bool(true),
+	}, pulumi.Provider(e.provider), pulumi.Parent(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("creating service account %s: %w", zoneServiceAccount, err)
+	}
+	crb, err := rbac.NewClusterRoleBinding(e.ctx, "elasticsearch-zone-crb", &rbac.ClusterRoleBindingArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name: pulumi.String("elasticsearch-zone-crb"),
+		},
+		Subjects: &rbac.SubjectArray{
+			&rbac.SubjectArgs{
+				Kind:      pulumi.String("ServiceAccount"),
+				Name:      serviceAccount.Metadata.Name().Elem(),
+				Namespace: namespace.Metadata.Name(),
+			},
+		},
+		RoleRef: &rbac.RoleRefArgs{
+			ApiGroup: pulumi.String("rbac.authorization.k8s.io"),
+			Kind:     pulumi.String("ClusterRole"),
+			Name:     clusterRole.Metadata.Name().Elem(),
+		},
+	}, pulumi.Provider(e.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{serviceAccount, clusterRole}))
+	if err != nil {
+		return nil, fmt.Errorf("creating cluster role binding elasticsearch-zone-crb: %w", err)
+	}
+	return crb, nil
+}
@@ -1,16 +1,19 @@
 package elasticsearchlogging
 
 import (
+	"fmt"
+
 	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes"
 	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
 	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
 	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/meta/v1"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+	"github.com/rodrigoafernandes/efk-cluster/readiness"
 )
 
 type Elasticsearch interface {
-	CreateResources(parent pulumi.Resource) (*corev1.Namespace, *helm.Release, error)
+	CreateResources(parent pulumi.Resource) (*corev1.Namespace, pulumi.Resource, error)
 }
 
 type resource struct {
@@ -19,7 +22,7 @@ type resource struct {
 	cfg      *config.Config
 }
 
-func (e resource) CreateResources(parent pulumi.Resource) (*corev1.Namespace, *helm.Release, error) {
+func (e resource) CreateResources(parent pulumi.Resource) (*corev1.Namespace, pulumi.Resource, error) {
 	namespace, err := corev1.NewNamespace(e.ctx, "efk-namespace", &corev1.NamespaceArgs{
 		Metadata: &metav1.ObjectMetaArgs{
 			Labels: pulumi.StringMap{
@@ -28,9 +31,74 @@ func (e resource) CreateResources(parent pulumi.Resource) (*corev1.Namespace, *h
 			Name: pulumi.String("efk-logging"),
 		},
 	}, pulumi.Provider(e.provider), pulumi.DependsOn([]pulumi.Resource{parent}))
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating namespace efk-namespace: %w", err)
+	}
+	values := pulumi.Map{
+		"global": pulumi.Map{
+			"storageClass": pulumi.String("linode-block-storage"),
+		},
+		// Security adds users and roles; the REST API stays on plain HTTP for the in-cluster clients.
+		"security": pulumi.Map{
+			"enabled":         pulumi.Bool(e.cfg.GetBool("elasticsearch_security")),
+			"elasticPassword": e.cfg.GetSecret("elasticsearch_pwd"),
+			"tls": pulumi.Map{
+				"restEncryption": pulumi.Bool(false),
+				"autoGenerated":  pulumi.Bool(true),
+			},
+		},
+	}
+	topology, err := configureTopology(e.cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	for key, value := range topology.values() {
+		values[key] = value
+	}
+	resources, err := configureSizing(e.cfg, topology)
 	if err != nil {
 		return nil, nil, err
 	}
+	for _, r := range topology.roles() {
+		for key, value := range resources[r.key].values() {
+			values[r.key].(pulumi.Map)[key] = value
+		}
+	}
+	settings := topology.settings()
+	// The self-generated basic license lacks document level security, which kibana_teams
+	// needs to scope teams by namespace. A trial enables it for 30 days; platinum and
+	// enterprise stand for a license uploaded through the license API.
+	switch license := e.cfg.Get("elasticsearch_license"); license {
+	case "", "basic", "platinum", "enterprise":
+	case "trial":
+		settings["xpack.license.self_generated.type"] = pulumi.String("trial")
+	default:
+		return nil, nil, fmt.Errorf("elasticsearch_license %q is not one of basic, trial, platinum, enterprise", license)
+	}
+	snapshots, err := e.configureSnapshotStore()
+	if err != nil {
+		return nil, nil, err
+	}
+	var dependsOn []pulumi.Resource
+	if topology.Awareness {
+		zoneReader, err := e.createZoneReader(namespace)
+		if err != nil {
+			return nil, nil, err
+		}
+		dependsOn = append(dependsOn, zoneReader)
+	}
+	if snapshots != nil {
+		keystore, err := e.createSnapshotKeystore(namespace, snapshots)
+		if err != nil {
+			return nil, nil, err
+		}
+		for key, value := range snapshots.settings() {
+			settings[key] = value
+		}
+		values["extraEnvVarsSecret"] = keystore.Metadata.Name()
+		dependsOn = append(dependsOn, keystore)
+	}
+	values["extraConfig"] = settings
 	release, err := helm.NewRelease(e.ctx, "elasticsearch", &helm.ReleaseArgs{
 		Name:      pulumi.String("elasticsearch"),
 		Namespace: namespace.Metadata.Name(),
@@ -39,17 +107,40 @@ func (e resource) CreateResources(parent pulumi.Resource) (*corev1.Namespace, *h
 		RepositoryOpts: helm.RepositoryOptsArgs{
 			Repo: pulumi.String("https://charts.bitnami.com/bitnami"),
 		},
-		Values: pulumi.Map{
-			"global": pulumi.Map{
-				"storageClass": pulumi.String("linode-block-storage"),
-			},
-			"security": pulumi.Map{
-				"elasticPassword": e.cfg.GetSecret("elasticsearch_pwd"),
-			},
-		},
+		Values:  values,
 		Timeout: pulumi.Int(600),
-	}, pulumi.Provider(e.provider), pulumi.Parent(namespace))
-	return namespace, release, err
+	}, pulumi.Provider(e.provider), pulumi.Parent(namespace), pulumi.DependsOn(dependsOn))
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating release elasticsearch: %w", err)
+	}
+	budgets, err := e.createDisruptionBudgets(namespace, topology, release)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Yellow is enough to index and search, replica shards may still be allocating.
+	ready, err := readiness.NewJob(e.ctx, e.provider, namespace, readiness.Check{
+		Name:     "elasticsearch-ready",
+		URL:      elasticsearchURL + "/_cluster/health?wait_for_status=yellow&timeout=5s",
+		Expect:   `"timed_out":false`,
+		User:     e.cfg.Get("elasticsearch_user"),
+		Password: e.cfg.GetSecret("elasticsearch_pwd"),
+	}, append(budgets, release)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	credentials, err := e.createAPICredentials(namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err = e.createIndexTemplates(namespace, credentials, ready); err != nil {
+		return nil, nil, err
+	}
+	if snapshots != nil {
+		if _, err = e.createSnapshotPolicy(namespace, snapshots, credentials, ready); err != nil {
+			return nil, nil, err
+		}
+	}
+	return namespace, ready, nil
 }
 
 func NewElasticsearch(ctx *pulumi.Context, provider *kubernetes.Provider, cfg *config.Config) Elasticsearch {
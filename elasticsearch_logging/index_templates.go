@@ -0,0 +1,167 @@
+package elasticsearchlogging
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	batchv1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/batch/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	logindices "github.com/rodrigoafernandes/efk-cluster/log_indices"
+)
+
+const (
+	elasticsearchURL = "http://elasticsearch.efk-logging.svc.cluster.local:9200"
+	ecsIndexTemplate = "elasticsearch_logging/ecs-index-template.json"
+)
+
+// createAPICredentials stores the credentials the Jobs calling the Elasticsearch API use.
+func (e resource) createAPICredentials(namespace *corev1.Namespace) (*corev1.Secret, error) {
+	secret, err := corev1.NewSecret(e.ctx, "elasticsearch-api-credentials", &corev1.SecretArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("elasticsearch-api-credentials"),
+			Namespace: namespace.Metadata.Name(),
+		},
+		Type: pulumi.String("Opaque"),
+		StringData: pulumi.StringMap{
+			"ELASTICSEARCH_USER":     pulumi.String(e.cfg.Get("elasticsearch_user")),
+			"ELASTICSEARCH_PASSWORD": e.cfg.GetSecret("elasticsearch_pwd"),
+		},
+	}, pulumi.Provider(e.provider), pulumi.Parent(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("creating secret elasticsearch-api-credentials: %w", err)
+	}
+	return secret, nil
+}
+
+// indexTemplates renders ecsIndexTemplate for the indices of the Fluentd routes: ecs-logs
+// for the plain indices and ecs-logs-datastreams for the data streams. The latter must win
+// over the built-in logs-*-* template, and keeps its ILM policy through logs-settings. A
+// template without index patterns is left out.
+func indexTemplates(indices []logindices.Index) (map[string][]byte, error) {
+	content, err := os.ReadFile(ecsIndexTemplate)
+	if err != nil {
+		return nil, err
+	}
+	templates := map[string][]byte{}
+	for _, dataStreams := range []bool{false, true} {
+		patterns := logindices.Patterns(indices, dataStreams)
+		if len(patterns) == 0 {
+			continue
+		}
+		var template map[string]interface{}
+		if err = json.Unmarshal(content, &template); err != nil {
+			return nil, fmt.Errorf("%s: %w", ecsIndexTemplate, err)
+		}
+		template["index_patterns"] = patterns
+		name := "ecs-logs"
+		if dataStreams {
+			name = "ecs-logs-datastreams"
+			template["data_stream"] = map[string]interface{}{}
+			template["priority"] = 200
+			template["composed_of"] = []string{"logs-settings"}
+		}
+		if templates[name], err = json.MarshalIndent(template, "", "  "); err != nil {
+			return nil, err
+		}
+	}
+	return templates, nil
+}
+
+// createIndexTemplates installs the ECS index templates through a Job, so every log index
+// gets the same mappings no matter which component writes to it.
+func (e resource) createIndexTemplates(namespace *corev1.Namespace, credentials *corev1.Secret, ready pulumi.Resource) (pulumi.Resource, error) {
+	indices, err := logindices.Resolve(e.cfg)
+	if err != nil {
+		return nil, err
+	}
+	templates, err := indexTemplates(indices)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	data := pulumi.StringMap{}
+	checksum := sha256.New()
+	for _, name := range names {
+		data[name+".json"] = pulumi.String(templates[name])
+		checksum.Write(templates[name])
+	}
+	configMap, err := corev1.NewConfigMap(e.ctx, "elasticsearch-index-templates", &corev1.ConfigMapArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("elasticsearch-index-templates"),
+			Namespace: namespace.Metadata.Name(),
+		},
+		Data: data,
+	}, pulumi.Provider(e.provider), pulumi.Parent(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("creating config map elasticsearch-index-templates: %w", err)
+	}
+	script := fmt.Sprintf(`set -e
+for template in /templates/*.json; do
+  curl -sf -u "$ELASTICSEARCH_USER:$ELASTICSEARCH_PASSWORD" -X PUT -H "Content-Type: application/json" \
+    "%s/_index_template/$(basename "$template" .json)" -d @"$template"
+done`, elasticsearchURL)
+	job, err := batchv1.NewJob(e.ctx, "elasticsearch-index-templates", &batchv1.JobArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Namespace: namespace.Metadata.Name(),
+		},
+		Spec: batchv1.JobSpecArgs{
+			BackoffLimit: pulumi.Int(4),
+			Template: corev1.PodTemplateSpecArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					// Changing the template changes the pod spec, which makes Pulumi run a new Job.
+					Annotations: pulumi.StringMap{
+						"checksum/index-templates": pulumi.String(fmt.Sprintf("%x", checksum.Sum(nil))),
+					},
+				},
+				Spec: corev1.PodSpecArgs{
+					RestartPolicy: pulumi.String("OnFailure"),
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:  pulumi.String("put-index-templates"),
+							Image: pulumi.String("docker.io/curlimages/curl:7.87.0"),
+							Command: pulumi.StringArray{
+								pulumi.String("sh"),
+								pulumi.String("-c"),
+								pulumi.String(script),
+							},
+							EnvFrom: corev1.EnvFromSourceArray{
+								corev1.EnvFromSourceArgs{
+									SecretRef: corev1.SecretEnvSourceArgs{
+										Name: credentials.Metadata.Name(),
+									},
+								},
+							},
+							VolumeMounts: corev1.VolumeMountArray{
+								corev1.VolumeMountArgs{
+									Name:      pulumi.String("templates"),
+									MountPath: pulumi.String("/templates"),
+								},
+							},
+						},
+					},
+					Volumes: corev1.VolumeArray{
+						corev1.VolumeArgs{
+							Name: pulumi.String("templates"),
+							ConfigMap: corev1.ConfigMapVolumeSourceArgs{
+								Name: configMap.Metadata.Name(),
+							},
+						},
+					},
+				},
+			},
+		},
+	}, pulumi.Provider(e.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{ready, credentials, configMap}))
+	if err != nil {
+		return nil, fmt.Errorf("creating job elasticsearch-index-templates: %w", err)
+	}
+	return job, nil
+}
@@ -0,0 +1,131 @@
+package elasticsearchlogging
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+	"github.com/rodrigoafernandes/efk-cluster/cluster"
+)
+
+const (
+	// maxHeapMB keeps the heap under the limit where the JVM stops using compressed pointers.
+	maxHeapMB = 31 * 1024
+	// minMemoryMi is the smallest container Elasticsearch starts in, with a 256m heap.
+	minMemoryMi = 512
+)
+
+var quantity = regexp.MustCompile(`^([0-9]+)(Mi|Gi)$`)
+
+// roleSizing is the share of a node given to one pod of a role. MemoryFraction sizes the
+// container from the node memory; Memory, e.g. "6Gi", replaces it with a fixed size.
+type roleSizing struct {
+	MemoryFraction float64 `json:"memory_fraction"`
+	Memory         string  `json:"memory"`
+}
+
+// sizing is read from the "elasticsearch_resources" config object, e.g.
+// {"data": {"memory_fraction": 0.5}, "master": {"memory": "1Gi"}}. Node types come from
+// node-types.json, "node_type" defaults to the plan of the cluster pool. The defaults
+// leave room for a master, a data, a coordinating and an ingest pod plus the rest of the
+// stack on each node.
+type sizing struct {
+	NodeType     string     `json:"node_type"`
+	Master       roleSizing `json:"master"`
+	Data         roleSizing `json:"data"`
+	Coordinating roleSizing `json:"coordinating"`
+	Ingest       roleSizing `json:"ingest"`
+}
+
+var defaultSizing = sizing{
+	Master:       roleSizing{MemoryFraction: 0.0625},
+	Data:         roleSizing{MemoryFraction: 0.375},
+	Coordinating: roleSizing{MemoryFraction: 0.0625},
+	Ingest:       roleSizing{MemoryFraction: 0.0625},
+}
+
+// roleResources are the resources of one pod of a role, memory in MiB and CPU in millicores.
+type roleResources struct {
+	MemoryMi int
+	HeapMB   int
+	CPUm     int
+}
+
+// configureSizing checks every role with replicas gets the minimum Elasticsearch starts
+// in, and that one pod of each of them fits on a node together.
+func configureSizing(cfg *config.Config, t topology) (map[string]roleResources, error) {
+	s := defaultSizing
+	if err := cfg.GetObject("elasticsearch_resources", &s); err != nil {
+		return nil, fmt.Errorf("elasticsearch_resources: %w", err)
+	}
+	if s.NodeType == "" {
+		s.NodeType = cluster.PoolNodeType(cfg)
+	}
+	node, err := cluster.LookupNodeType(s.NodeType)
+	if err != nil {
+		return nil, fmt.Errorf("elasticsearch_resources: %w", err)
+	}
+	sizes := map[string]roleSizing{"master": s.Master, "data": s.Data, "coordinating": s.Coordinating, "ingest": s.Ingest}
+	resources := map[string]roleResources{}
+	totalMi := 0
+	for _, role := range t.roles() {
+		key, r := role.key, sizes[role.key]
+		fraction := r.MemoryFraction
+		memoryMi := int(float64(node.Memory) * fraction)
+		if r.Memory != "" {
+			match := quantity.FindStringSubmatch(r.Memory)
+			if match == nil {
+				return nil, fmt.Errorf("elasticsearch_resources: %s memory %q is not a quantity in Mi or Gi", key, r.Memory)
+			}
+			memoryMi, _ = strconv.Atoi(match[1])
+			if match[2] == "Gi" {
+				memoryMi *= 1024
+			}
+			fraction = float64(memoryMi) / float64(node.Memory)
+		}
+		if fraction <= 0 || fraction > 1 {
+			return nil, fmt.Errorf("elasticsearch_resources: %s needs between 0 and all of the %d MB of a %s node", key, node.Memory, node.ID)
+		}
+		// Rounded down to 64Mi so small changes of the node memory do not restart the pods.
+		memoryMi -= memoryMi % 64
+		if role.replicas > 0 && memoryMi < minMemoryMi {
+			return nil, fmt.Errorf("elasticsearch_resources: %s gets %dMi of a %s node, it needs at least %dMi", key, memoryMi, node.ID, minMemoryMi)
+		}
+		heapMB := memoryMi / 2
+		if heapMB > maxHeapMB {
+			heapMB = maxHeapMB
+		}
+		resources[key] = roleResources{
+			MemoryMi: memoryMi,
+			HeapMB:   heapMB,
+			CPUm:     int(float64(node.VCPUs*1000) * fraction),
+		}
+		if role.replicas > 0 {
+			totalMi += memoryMi
+		}
+	}
+	if totalMi > node.Memory {
+		return nil, fmt.Errorf("elasticsearch_resources: a pod of each role takes %dMi together, more than the %d MB of a %s node", totalMi, node.Memory, node.ID)
+	}
+	return resources, nil
+}
+
+// values give half of the container memory to the heap, the other half is left to the
+// filesystem cache Lucene relies on. Memory is requested in full so pods are never
+// scheduled on a node that cannot hold them; CPU is only requested, bursts are free.
+func (r roleResources) values() pulumi.Map {
+	return pulumi.Map{
+		"heapSize": pulumi.String(fmt.Sprintf("%dm", r.HeapMB)),
+		"resources": pulumi.Map{
+			"requests": pulumi.Map{
+				"cpu":    pulumi.String(fmt.Sprintf("%dm", r.CPUm)),
+				"memory": pulumi.String(fmt.Sprintf("%dMi", r.MemoryMi)),
+			},
+			"limits": pulumi.Map{
+				"memory": pulumi.String(fmt.Sprintf("%dMi", r.MemoryMi)),
+			},
+		},
+	}
+}
@@ -0,0 +1,260 @@
+package elasticsearchlogging
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	batchv1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/batch/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi-linode/sdk/v3/go/linode"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	logarchive "github.com/rodrigoafernandes/efk-cluster/log_archive"
+)
+
+const (
+	// SnapshotRepository is the name the S3 repository is registered with.
+	SnapshotRepository = "es-snapshots"
+
+	defaultSnapshotBucket        = "efk-es-snapshots"
+	defaultSnapshotSchedule      = "0 30 1 * * ?"
+	defaultSnapshotRetentionDays = 30
+)
+
+// snapshotStore is the S3-compatible storage the snapshot repository writes to.
+type snapshotStore struct {
+	Bucket pulumi.StringInput
+	// Endpoint is the host and port of the S3 API, without the scheme.
+	Endpoint  pulumi.StringInput
+	Protocol  string
+	PathStyle bool
+	AccessKey pulumi.StringInput
+	SecretKey pulumi.StringInput
+	Resource  pulumi.Resource
+}
+
+// configureSnapshotStore provisions the storage selected by "elasticsearch_snapshots":
+// a bucket of its own on Linode Object Storage, or the snapshot bucket the MinIO archive
+// of local stacks creates. It returns nil when snapshots are disabled.
+func (e resource) configureSnapshotStore() (*snapshotStore, error) {
+	switch kind := e.cfg.Get("elasticsearch_snapshots"); kind {
+	case "":
+		return nil, nil
+	case "linode":
+		return e.linodeSnapshotStore()
+	case "minio":
+		// MinIO and its buckets are created with the log archive, after Elasticsearch, so
+		// the repository Job retries until the bucket answers.
+		if e.cfg.Get("log_archive") != "minio" {
+			return nil, fmt.Errorf("elasticsearch_snapshots minio needs log_archive minio, which runs the MinIO server")
+		}
+		return &snapshotStore{
+			Bucket:    pulumi.String(logarchive.SnapshotBucketName(e.cfg)),
+			Endpoint:  pulumi.String("minio.efk-logging.svc.cluster.local:9000"),
+			Protocol:  "http",
+			PathStyle: true,
+			AccessKey: pulumi.String(e.cfg.Get("minio_user")),
+			SecretKey: e.cfg.GetSecret("minio_pwd"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("elasticsearch_snapshots %q is not one of linode, minio", kind)
+	}
+}
+
+// linodeSnapshotStore creates a private bucket and a key restricted to it. The bucket has
+// no lifecycle rule: snapshots share files, so only the SLM retention may delete them.
+func (e resource) linodeSnapshotStore() (*snapshotStore, error) {
+	cluster := e.cfg.Get("elasticsearch_snapshot_cluster")
+	if cluster == "" {
+		cluster = "us-southeast-1"
+	}
+	name := e.cfg.Get("elasticsearch_snapshot_bucket")
+	if name == "" {
+		name = defaultSnapshotBucket
+	}
+	bucket, err := linode.NewObjectStorageBucket(e.ctx, "es-snapshot-bucket", &linode.ObjectStorageBucketArgs{
+		Cluster: pulumi.String(cluster),
+		Label:   pulumi.String(name),
+		Acl:     pulumi.String("private"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating object storage bucket es-snapshot-bucket: %w", err)
+	}
+	key, err := linode.NewObjectStorageKey(e.ctx, "es-snapshot-key", &linode.ObjectStorageKeyArgs{
+		Label: pulumi.String(name + "-elasticsearch"),
+		BucketAccesses: linode.ObjectStorageKeyBucketAccessArray{
+			linode.ObjectStorageKeyBucketAccessArgs{
+				BucketName:  bucket.Label,
+				Cluster:     bucket.Cluster,
+				Permissions: pulumi.String("read_write"),
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating object storage key es-snapshot-key: %w", err)
+	}
+	return &snapshotStore{
+		Bucket:    bucket.Label,
+		Endpoint:  pulumi.Sprintf("%s.linodeobjects.com", bucket.Cluster),
+		Protocol:  "https",
+		AccessKey: key.AccessKey,
+		SecretKey: pulumi.ToSecret(key.SecretKey).(pulumi.StringOutput),
+		Resource:  key,
+	}, nil
+}
+
+// settings are the elasticsearch.yml entries of the S3 client every node uses.
+func (s snapshotStore) settings() pulumi.Map {
+	return pulumi.Map{
+		"s3.client.default.endpoint":          s.Endpoint,
+		"s3.client.default.protocol":          pulumi.String(s.Protocol),
+		"s3.client.default.path_style_access": pulumi.Bool(s.PathStyle),
+	}
+}
+
+// createSnapshotKeystore holds the S3 keys in ELASTICSEARCH_KEYS, which the Bitnami image
+// adds to the keystore on start.
+func (e resource) createSnapshotKeystore(namespace *corev1.Namespace, store *snapshotStore) (*corev1.Secret, error) {
+	var dependsOn []pulumi.Resource
+	if store.Resource != nil {
+		dependsOn = append(dependsOn, store.Resource)
+	}
+	secret, err := corev1.NewSecret(e.ctx, "elasticsearch-snapshot-keystore", &corev1.SecretArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("elasticsearch-snapshot-keystore"),
+			Namespace: namespace.Metadata.Name(),
+		},
+		Type: pulumi.String("Opaque"),
+		StringData: pulumi.StringMap{
+			"ELASTICSEARCH_KEYS": pulumi.Sprintf("s3.client.default.access_key=%s,s3.client.default.secret_key=%s", store.AccessKey, store.SecretKey),
+		},
+	}, pulumi.Provider(e.provider), pulumi.Parent(namespace), pulumi.DependsOn(dependsOn))
+	if err != nil {
+		return nil, fmt.Errorf("creating secret elasticsearch-snapshot-keystore: %w", err)
+	}
+	return secret, nil
+}
+
+// slmPolicy takes a snapshot of every index on "elasticsearch_snapshot_schedule", a cron
+// expression in UTC, and deletes the ones older than "elasticsearch_snapshot_retention_days"
+// while keeping at least five.
+func (e resource) slmPolicy() (string, error) {
+	schedule := e.cfg.Get("elasticsearch_snapshot_schedule")
+	if schedule == "" {
+		schedule = defaultSnapshotSchedule
+	}
+	retentionDays := e.cfg.GetInt("elasticsearch_snapshot_retention_days")
+	if retentionDays <= 0 {
+		retentionDays = defaultSnapshotRetentionDays
+	}
+	policy, err := json.Marshal(map[string]interface{}{
+		"schedule":   schedule,
+		"name":       "<scheduled-{now/d}>",
+		"repository": SnapshotRepository,
+		"config": map[string]interface{}{
+			"indices":              []string{"*"},
+			"include_global_state": true,
+		},
+		"retention": map[string]interface{}{
+			"expire_after": fmt.Sprintf("%dd", retentionDays),
+			"min_count":    5,
+			"max_count":    100,
+		},
+	})
+	return string(policy), err
+}
+
+// createSnapshotPolicy registers the repository and the SLM policy through a Job.
+func (e resource) createSnapshotPolicy(namespace *corev1.Namespace, store *snapshotStore, credentials *corev1.Secret, ready pulumi.Resource) (pulumi.Resource, error) {
+	policy, err := e.slmPolicy()
+	if err != nil {
+		return nil, err
+	}
+	repository := store.Bucket.ToStringOutput().ApplyT(func(bucket string) (string, error) {
+		content, err := json.Marshal(map[string]interface{}{
+			"type": "s3",
+			"settings": map[string]interface{}{
+				"bucket": bucket,
+				"client": "default",
+			},
+		})
+		return string(content), err
+	}).(pulumi.StringOutput)
+	configMap, err := corev1.NewConfigMap(e.ctx, "elasticsearch-snapshots", &corev1.ConfigMapArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("elasticsearch-snapshots"),
+			Namespace: namespace.Metadata.Name(),
+		},
+		Data: pulumi.StringMap{
+			"repository.json": repository,
+			"slm-policy.json": pulumi.String(policy),
+		},
+	}, pulumi.Provider(e.provider), pulumi.Parent(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("creating config map elasticsearch-snapshots: %w", err)
+	}
+	// Registering the repository verifies that every node can write to the bucket.
+	script := fmt.Sprintf(`set -e
+auth="$ELASTICSEARCH_USER:$ELASTICSEARCH_PASSWORD"
+until curl -sf -u "$auth" -X PUT -H "Content-Type: application/json" \
+  "%[1]s/_snapshot/%[2]s" -d @/snapshots/repository.json; do echo "waiting for the snapshot store"; sleep 10; done
+curl -sf -u "$auth" -X PUT -H "Content-Type: application/json" \
+  "%[1]s/_slm/policy/scheduled-snapshots" -d @/snapshots/slm-policy.json`, elasticsearchURL, SnapshotRepository)
+	job, err := batchv1.NewJob(e.ctx, "elasticsearch-snapshots", &batchv1.JobArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Namespace: namespace.Metadata.Name(),
+		},
+		Spec: batchv1.JobSpecArgs{
+			BackoffLimit:          pulumi.Int(4),
+			ActiveDeadlineSeconds: pulumi.Int(900),
+			Template: corev1.PodTemplateSpecArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					// Changing the policy changes the pod spec, which makes Pulumi run a new Job.
+					Annotations: pulumi.StringMap{
+						"checksum/slm-policy": pulumi.String(fmt.Sprintf("%x", sha256.Sum256([]byte(policy)))),
+					},
+				},
+				Spec: corev1.PodSpecArgs{
+					RestartPolicy: pulumi.String("OnFailure"),
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:  pulumi.String("put-snapshot-policy"),
+							Image: pulumi.String("docker.io/curlimages/curl:7.87.0"),
+							Command: pulumi.StringArray{
+								pulumi.String("sh"),
+								pulumi.String("-c"),
+								pulumi.String(script),
+							},
+							EnvFrom: corev1.EnvFromSourceArray{
+								corev1.EnvFromSourceArgs{
+									SecretRef: corev1.SecretEnvSourceArgs{
+										Name: credentials.Metadata.Name(),
+									},
+								},
+							},
+							VolumeMounts: corev1.VolumeMountArray{
+								corev1.VolumeMountArgs{
+									Name:      pulumi.String("snapshots"),
+									MountPath: pulumi.String("/snapshots"),
+								},
+							},
+						},
+					},
+					Volumes: corev1.VolumeArray{
+						corev1.VolumeArgs{
+							Name: pulumi.String("snapshots"),
+							ConfigMap: corev1.ConfigMapVolumeSourceArgs{
+								Name: configMap.Metadata.Name(),
+							},
+						},
+					},
+				},
+			},
+		},
+	}, pulumi.Provider(e.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{ready, credentials, configMap}))
+	if err != nil {
+		return nil, fmt.Errorf("creating job elasticsearch-snapshots: %w", err)
+	}
+	return job, nil
+}
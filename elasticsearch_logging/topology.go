@@ -0,0 +1,175 @@
+package elasticsearchlogging
+
+import (
+	"fmt"
+
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/meta/v1"
+	policyv1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/policy/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// topology is read from the "elasticsearch_topology" config object, e.g.
+// {"masters": 3, "data": 4, "anti_affinity": "soft", "topology_key": "topology.kubernetes.io/zone"}.
+// Unset fields keep the defaults below, sized for the 3-node pool of the cluster.
+type topology struct {
+	Masters      int `json:"masters"`
+	Data         int `json:"data"`
+	Coordinating int `json:"coordinating"`
+	Ingest       int `json:"ingest"`
+	// AntiAffinity is hard or soft, for the master and data nodes. Coordinating and ingest
+	// nodes hold no state and are always spread softly.
+	AntiAffinity string `json:"anti_affinity"`
+	// TopologyKey is the node label pods of the same role must not share.
+	TopologyKey string `json:"topology_key"`
+	// DataMaxUnavailable is how many data nodes an eviction may take down at once.
+	DataMaxUnavailable int `json:"data_max_unavailable"`
+	// Awareness spreads the copies of a shard over distinct values of TopologyKey, which
+	// soft anti-affinity alone does not guarantee.
+	Awareness bool `json:"awareness"`
+}
+
+var defaultTopology = topology{
+	Masters:            3,
+	Data:               3,
+	Coordinating:       2,
+	Ingest:             2,
+	AntiAffinity:       "hard",
+	TopologyKey:        "kubernetes.io/hostname",
+	DataMaxUnavailable: 1,
+	Awareness:          true,
+}
+
+// role is a node role of the chart, with the component label of its pods.
+type role struct {
+	key       string
+	component string
+	replicas  int
+}
+
+func configureTopology(cfg *config.Config) (topology, error) {
+	t := defaultTopology
+	if err := cfg.GetObject("elasticsearch_topology", &t); err != nil {
+		return t, fmt.Errorf("elasticsearch_topology: %w", err)
+	}
+	if t.Masters < 1 || t.Masters%2 == 0 {
+		return t, fmt.Errorf("elasticsearch_topology: masters must be odd, %d masters tolerate no more failures than %d", t.Masters, t.Masters-1)
+	}
+	// The elasticsearch Service every client uses routes to the coordinating nodes.
+	if t.Data < 1 || t.Coordinating < 1 || t.Ingest < 0 {
+		return t, fmt.Errorf("elasticsearch_topology: data and coordinating must be at least 1, ingest at least 0")
+	}
+	if t.AntiAffinity != "hard" && t.AntiAffinity != "soft" {
+		return t, fmt.Errorf("elasticsearch_topology: anti_affinity %q is not one of hard, soft", t.AntiAffinity)
+	}
+	if t.AntiAffinity == "soft" && !t.Awareness {
+		return t, fmt.Errorf("elasticsearch_topology: soft anti_affinity may schedule a primary and its replica on the same %s, it needs awareness", t.TopologyKey)
+	}
+	if t.DataMaxUnavailable < 1 || (t.Data > 1 && t.DataMaxUnavailable >= t.Data) {
+		return t, fmt.Errorf("elasticsearch_topology: data_max_unavailable must be between 1 and %d", t.Data-1)
+	}
+	return t, nil
+}
+
+func (t topology) roles() []role {
+	return []role{
+		{key: "master", component: "master", replicas: t.Masters},
+		{key: "data", component: "data", replicas: t.Data},
+		{key: "coordinating", component: "coordinating-only", replicas: t.Coordinating},
+		{key: "ingest", component: "ingest", replicas: t.Ingest},
+	}
+}
+
+func (r role) labels() pulumi.StringMap {
+	return pulumi.StringMap{
+		"app.kubernetes.io/name":      pulumi.String("elasticsearch"),
+		"app.kubernetes.io/instance":  pulumi.String("elasticsearch"),
+		"app.kubernetes.io/component": pulumi.String(r.component),
+	}
+}
+
+// affinity keeps the pods of a role on distinct values of the topology key, so losing
+// a node takes down at most one master and one copy of each shard.
+func (t topology) affinity(r role) pulumi.Map {
+	term := pulumi.Map{
+		"labelSelector": pulumi.Map{
+			"matchLabels": r.labels(),
+		},
+		"topologyKey": pulumi.String(t.TopologyKey),
+	}
+	if t.AntiAffinity == "hard" && (r.key == "master" || r.key == "data") {
+		return pulumi.Map{
+			"podAntiAffinity": pulumi.Map{
+				"requiredDuringSchedulingIgnoredDuringExecution": pulumi.Array{term},
+			},
+		}
+	}
+	return pulumi.Map{
+		"podAntiAffinity": pulumi.Map{
+			"preferredDuringSchedulingIgnoredDuringExecution": pulumi.Array{
+				pulumi.Map{
+					"weight":          pulumi.Int(100),
+					"podAffinityTerm": term,
+				},
+			},
+		},
+	}
+}
+
+// values sets the replicas and affinity of every role, and the zone of the data nodes
+// when awareness is on.
+func (t topology) values() pulumi.Map {
+	values := pulumi.Map{}
+	for _, r := range t.roles() {
+		role := pulumi.Map{
+			"replicaCount": pulumi.Int(r.replicas),
+			"affinity":     t.affinity(r),
+		}
+		if t.Awareness && r.key == "data" {
+			for key, value := range t.awarenessValues() {
+				role[key] = value
+			}
+		}
+		values[r.key] = role
+	}
+	return values
+}
+
+// createDisruptionBudgets keeps a quorum of masters and all but DataMaxUnavailable data
+// nodes up while nodes are drained. Roles with a single replica get none, it would block
+// every drain.
+func (e resource) createDisruptionBudgets(namespace *corev1.Namespace, t topology, release pulumi.Resource) ([]pulumi.Resource, error) {
+	var budgets []pulumi.Resource
+	for _, r := range t.roles() {
+		if r.replicas < 2 {
+			continue
+		}
+		spec := policyv1.PodDisruptionBudgetSpecArgs{
+			Selector: &metav1.LabelSelectorArgs{
+				MatchLabels: r.labels(),
+			},
+		}
+		switch r.key {
+		case "master":
+			spec.MinAvailable = pulumi.Int(r.replicas/2 + 1)
+		case "data":
+			spec.MaxUnavailable = pulumi.Int(t.DataMaxUnavailable)
+		default:
+			spec.MaxUnavailable = pulumi.Int(1)
+		}
+		name := fmt.Sprintf("elasticsearch-%s-pdb", r.key)
+		budget, err := policyv1.NewPodDisruptionBudget(e.ctx, name, &policyv1.PodDisruptionBudgetArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String(name),
+				Namespace: namespace.Metadata.Name(),
+			},
+			Spec: spec,
+		}, pulumi.Provider(e.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{release}))
+		if err != nil {
+			return nil, fmt.Errorf("creating pod disruption budget %s: %w", name, err)
+		}
+		budgets = append(budgets, budget)
+	}
+	return budgets, nil
+}
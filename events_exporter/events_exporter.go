@@ -0,0 +1,161 @@
+package eventsexporter
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes"
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/apps/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/meta/v1"
+	rbac "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/rbac/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// EventsExporter watches cluster events and prints them as JSON lines, so Fluentd
+// can pick them up from the container logs like any other workload.
+type EventsExporter interface {
+	CreateResources(namespace *corev1.Namespace, dependsOn ...pulumi.Resource) (pulumi.Resource, error)
+}
+
+type resource struct {
+	ctx      *pulumi.Context
+	provider *kubernetes.Provider
+}
+
+func NewEventsExporter(context *pulumi.Context, provider *kubernetes.Provider) EventsExporter {
+	return resource{
+		ctx:      context,
+		provider: provider,
+	}
+}
+
+func (e resource) CreateResources(namespace *corev1.Namespace, dependsOn ...pulumi.Resource) (pulumi.Resource, error) {
+	eventRouterLabels := pulumi.StringMap{
+		"app": pulumi.String("eventrouter"),
+	}
+	serviceAccount, err := corev1.NewServiceAccount(e.ctx, "eventrouter-sa", &corev1.ServiceAccountArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("eventrouter"),
+			Namespace: namespace.Metadata.Name(),
+			Labels:    eventRouterLabels,
+		},
+	}, pulumi.Provider(e.provider), pulumi.Parent(namespace), pulumi.DependsOn(dependsOn))
+	if err != nil {
+		return nil, fmt.Errorf("creating service account eventrouter-sa: %w", err)
+	}
+	clusterRole, err := rbac.NewClusterRole(e.ctx, "eventrouter-cr", &rbac.ClusterRoleArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:   pulumi.String("eventrouter"),
+			Labels: eventRouterLabels,
+		},
+		Rules: &rbac.PolicyRuleArray{
+			&rbac.PolicyRuleArgs{
+				ApiGroups: pulumi.StringArray{
+					pulumi.String(""),
+				},
+				Resources: pulumi.StringArray{
+					pulumi.String("events"),
+				},
+				Verbs: pulumi.StringArray{
+					pulumi.String("get"),
+					pulumi.String("watch"),
+					pulumi.String("list"),
+				},
+			},
+		},
+	}, pulumi.Provider(e.provider), pulumi.Parent(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("creating cluster role eventrouter-cr: %w", err)
+	}
+	crb, err := rbac.NewClusterRoleBinding(e.ctx, "eventrouter-crb", &rbac.ClusterRoleBindingArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:   pulumi.String("eventrouter"),
+			Labels: eventRouterLabels,
+		},
+		Subjects: &rbac.SubjectArray{
+			&rbac.SubjectArgs{
+				Kind:      pulumi.String("ServiceAccount"),
+				Name:      serviceAccount.Metadata.Name().Elem(),
+				Namespace: namespace.Metadata.Name(),
+			},
+		},
+		RoleRef: &rbac.RoleRefArgs{
+			ApiGroup: pulumi.String("rbac.authorization.k8s.io"),
+			Kind:     pulumi.String("ClusterRole"),
+			Name:     clusterRole.Metadata.Name().Elem(),
+		},
+	}, pulumi.Provider(e.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{serviceAccount, clusterRole}))
+	if err != nil {
+		return nil, fmt.Errorf("creating cluster role binding eventrouter-crb: %w", err)
+	}
+	configMap, err := corev1.NewConfigMap(e.ctx, "eventrouter-cm", &corev1.ConfigMapArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("eventrouter-cm"),
+			Namespace: namespace.Metadata.Name(),
+			Labels:    eventRouterLabels,
+		},
+		Data: pulumi.StringMap{
+			"config.json": pulumi.String(`{"sink": "stdout"}`),
+		},
+	}, pulumi.Provider(e.provider), pulumi.Parent(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("creating config map eventrouter-cm: %w", err)
+	}
+	// The pod and container names are part of the log file name Fluentd routes to the k8s-events index.
+	deployment, err := appsv1.NewDeployment(e.ctx, "eventrouter", &appsv1.DeploymentArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("eventrouter"),
+			Namespace: namespace.Metadata.Name(),
+			Labels:    eventRouterLabels,
+		},
+		Spec: appsv1.DeploymentSpecArgs{
+			Selector: &metav1.LabelSelectorArgs{
+				MatchLabels: eventRouterLabels,
+			},
+			Replicas: pulumi.Int(1),
+			Template: &corev1.PodTemplateSpecArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					Labels: eventRouterLabels,
+				},
+				Spec: &corev1.PodSpecArgs{
+					ServiceAccountName: serviceAccount.Metadata.Name(),
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:            pulumi.String("eventrouter"),
+							Image:           pulumi.String("gcr.io/heptio-images/eventrouter:v0.3"),
+							ImagePullPolicy: pulumi.String("IfNotPresent"),
+							Resources: &corev1.ResourceRequirementsArgs{
+								Requests: pulumi.StringMap{
+									"memory": pulumi.String("32Mi"),
+									"cpu":    pulumi.String("10m"),
+								},
+								Limits: pulumi.StringMap{
+									"memory": pulumi.String("128Mi"),
+									"cpu":    pulumi.String("100m"),
+								},
+							},
+							VolumeMounts: corev1.VolumeMountArray{
+								corev1.VolumeMountArgs{
+									Name:      pulumi.String("config-volume"),
+									MountPath: pulumi.String("/etc/eventrouter"),
+								},
+							},
+						},
+					},
+					Volumes: corev1.VolumeArray{
+						corev1.VolumeArgs{
+							Name: pulumi.String("config-volume"),
+							ConfigMap: corev1.ConfigMapVolumeSourceArgs{
+								Name: configMap.Metadata.Name(),
+							},
+						},
+					},
+				},
+			},
+		},
+	}, pulumi.Provider(e.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{crb, configMap}))
+	if err != nil {
+		return nil, fmt.Errorf("creating deployment eventrouter: %w", err)
+	}
+	return deployment, nil
+}
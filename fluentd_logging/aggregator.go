@@ -0,0 +1,87 @@
+package fluentdlogging
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// aggregatorSizing is read from the "fluentd_aggregator" config object, e.g.
+// {"replicas": 3, "max_replicas": 8, "target_cpu": 60, "memory_limit": "2Gi", "buffer_size": "20Gi"}.
+// Unset fields keep the defaults below.
+type aggregatorSizing struct {
+	Replicas      int    `json:"replicas"`
+	MaxReplicas   int    `json:"max_replicas"`
+	TargetCPU     int    `json:"target_cpu"`
+	CPURequest    string `json:"cpu_request"`
+	MemoryRequest string `json:"memory_request"`
+	CPULimit      string `json:"cpu_limit"`
+	MemoryLimit   string `json:"memory_limit"`
+	BufferSize    string `json:"buffer_size"`
+	MinAvailable  int    `json:"min_available"`
+}
+
+var defaultAggregatorSizing = aggregatorSizing{
+	Replicas:      2,
+	MaxReplicas:   6,
+	TargetCPU:     70,
+	CPURequest:    "250m",
+	MemoryRequest: "512Mi",
+	CPULimit:      "1",
+	MemoryLimit:   "1Gi",
+	BufferSize:    "10Gi",
+	MinAvailable:  1,
+}
+
+func configureAggregator(cfg *config.Config) (aggregatorSizing, error) {
+	sizing := defaultAggregatorSizing
+	if err := cfg.GetObject("fluentd_aggregator", &sizing); err != nil {
+		return sizing, fmt.Errorf("fluentd_aggregator: %w", err)
+	}
+	if sizing.Replicas < 1 {
+		return sizing, fmt.Errorf("fluentd_aggregator: replicas must be at least 1")
+	}
+	if sizing.MaxReplicas < sizing.Replicas {
+		return sizing, fmt.Errorf("fluentd_aggregator: max_replicas %d is below replicas %d", sizing.MaxReplicas, sizing.Replicas)
+	}
+	if sizing.MinAvailable >= sizing.Replicas {
+		return sizing, fmt.Errorf("fluentd_aggregator: min_available %d would block every eviction of %d replicas", sizing.MinAvailable, sizing.Replicas)
+	}
+	return sizing, nil
+}
+
+// values are the chart settings for the aggregator StatefulSet. Replicas is the floor
+// the HPA scales from, and each replica keeps its file buffers on its own volume, so
+// chunks survive restarts and are flushed once the pod comes back.
+func (s aggregatorSizing) values() pulumi.Map {
+	return pulumi.Map{
+		"replicaCount": pulumi.Int(s.Replicas),
+		"autoscaling": pulumi.Map{
+			"enabled":     pulumi.Bool(true),
+			"minReplicas": pulumi.Int(s.Replicas),
+			"maxReplicas": pulumi.Int(s.MaxReplicas),
+			"targetCPU":   pulumi.Int(s.TargetCPU),
+		},
+		"resources": pulumi.Map{
+			"requests": pulumi.Map{
+				"cpu":    pulumi.String(s.CPURequest),
+				"memory": pulumi.String(s.MemoryRequest),
+			},
+			"limits": pulumi.Map{
+				"cpu":    pulumi.String(s.CPULimit),
+				"memory": pulumi.String(s.MemoryLimit),
+			},
+		},
+		"persistence": pulumi.Map{
+			"enabled":      pulumi.Bool(true),
+			"storageClass": pulumi.String("linode-block-storage"),
+			"accessModes":  pulumi.StringArray{pulumi.String("ReadWriteOnce")},
+			"size":         pulumi.String(s.BufferSize),
+		},
+		"pdb": pulumi.Map{
+			"create":       pulumi.Bool(true),
+			"minAvailable": pulumi.Int(s.MinAvailable),
+		},
+	}
+}
@@ -0,0 +1,254 @@
+package fluentdlogging
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+
+	logbackend "github.com/rodrigoafernandes/efk-cluster/log_backend"
+	logindices "github.com/rodrigoafernandes/efk-cluster/log_indices"
+)
+
+const (
+	aggregatorTemplate = "fluentd_logging/fluentd.conf.tmpl"
+	indexerTemplate    = "fluentd_logging/indexer.conf.tmpl"
+	outputsTemplate    = "fluentd_logging/outputs.tmpl"
+	throttlePlugin     = "fluentd_logging/plugins/filter_group_throttle.rb"
+)
+
+// route sends every event whose tag matches Match to its own index, named by
+// configureRoutes. Routes are rendered in order, so more specific matches must come
+// before broader ones.
+type route struct {
+	Name      string
+	Match     string
+	Index     string
+	IndexMode logindices.Mode
+	Fields    []field
+}
+
+// field renames a key produced by a component to its Elastic Common Schema name.
+type field struct {
+	ECS    string
+	Source string
+}
+
+// Topic is the Kafka topic the route is buffered in when the Kafka tier is enabled.
+func (r route) Topic() string {
+	return "logs." + r.Index
+}
+
+// DataStreamName is the data stream the route writes to in the datastream mode.
+func (r route) DataStreamName() string {
+	return logindices.Index{Name: r.Index, Mode: r.IndexMode}.DataStreamName()
+}
+
+// SourceKeys lists the original keys, which are dropped once copied to their ECS names.
+func (r route) SourceKeys() string {
+	keys := make([]string, 0, len(r.Fields))
+	for _, f := range r.Fields {
+		keys = append(keys, f.Source)
+	}
+	return strings.Join(keys, ",")
+}
+
+var routes = []route{
+	{
+		Name:  "ingress-access",
+		Match: "kubernetes.var.log.containers.ingress-nginx-controller-*_nginx-ingress_controller-*.log",
+		Fields: []field{
+			{ECS: "http.request.id", Source: "request_id"},
+			{ECS: "source.address", Source: "remote_addr"},
+			{ECS: "url.domain", Source: "host"},
+			{ECS: "http.request.method", Source: "method"},
+			{ECS: "url.path", Source: "path"},
+			{ECS: "url.query", Source: "query"},
+			{ECS: "http.version", Source: "protocol"},
+			{ECS: "http.response.status_code", Source: "status"},
+			{ECS: "http.response.bytes", Source: "bytes_sent"},
+			{ECS: "http.request.referrer", Source: "referer"},
+			{ECS: "user_agent.original", Source: "user_agent"},
+			{ECS: "nginx.request_time", Source: "request_time"},
+			{ECS: "nginx.upstream.address", Source: "upstream"},
+			{ECS: "nginx.upstream.status", Source: "upstream_status"},
+			{ECS: "nginx.upstream.response_time", Source: "upstream_response_time"},
+			{ECS: "nginx.ingress.name", Source: "ingress"},
+			{ECS: "nginx.ingress.namespace", Source: "namespace"},
+			{ECS: "nginx.ingress.service", Source: "service"},
+		},
+	},
+	{
+		Name:  "k8s-events",
+		Match: "kubernetes.var.log.containers.eventrouter-*_efk-logging_eventrouter-*.log",
+	},
+	{
+		Name:  "apps-log",
+		Match: "kubernetes.var.log.containers.**",
+	},
+}
+
+// throttleRoute indexes the events the throttle filter emits for every group it dropped lines from.
+var throttleRoute = route{
+	Name:  "log-throttle",
+	Match: "fluentd.throttle",
+}
+
+// otlpRoute indexes the logs pushed through the OpenTelemetry Collector, together with
+// the container logs by default.
+var otlpRoute = route{
+	Name:  "otlp",
+	Match: "otlp.**",
+}
+
+// configureRoutes names the index of every route as resolved from the "log_indices"
+// overrides. A prefix replaces the index name; the route keeps matching the same events.
+func configureRoutes(cfg *config.Config, defaults []route) ([]route, error) {
+	indices, err := logindices.Resolve(cfg)
+	if err != nil {
+		return nil, err
+	}
+	configured := make([]route, 0, len(defaults))
+	for _, r := range defaults {
+		index, ok := logindices.Find(indices, r.Name)
+		if !ok {
+			return nil, fmt.Errorf("log_indices: route %s is not enabled", r.Name)
+		}
+		r.Index, r.IndexMode = index.Name, index.Mode
+		configured = append(configured, r)
+	}
+	return configured, nil
+}
+
+// throttle is the per group rate limit read from the "log_throttle" config object, e.g.
+// {"lines_per_second": 500, "group_by": "pod", "action": "sample", "sample_rate": 50}.
+type throttle struct {
+	LinesPerSecond int    `json:"lines_per_second"`
+	WindowSeconds  int    `json:"window_seconds"`
+	GroupBy        string `json:"group_by"`
+	Action         string `json:"action"`
+	SampleRate     int    `json:"sample_rate"`
+}
+
+// configureThrottle returns nil when "log_throttle" is not set, leaving floods unlimited.
+func configureThrottle(cfg *config.Config) (*throttle, error) {
+	var t *throttle
+	if err := cfg.GetObject("log_throttle", &t); err != nil {
+		return nil, fmt.Errorf("log_throttle: %w", err)
+	}
+	if t == nil {
+		return nil, nil
+	}
+	if t.LinesPerSecond <= 0 {
+		return nil, fmt.Errorf("log_throttle: lines_per_second must be positive")
+	}
+	if t.WindowSeconds <= 0 {
+		t.WindowSeconds = 10
+	}
+	switch t.GroupBy {
+	case "":
+		t.GroupBy = "namespace"
+	case "namespace", "pod":
+	default:
+		return nil, fmt.Errorf("log_throttle: unsupported group_by %q", t.GroupBy)
+	}
+	switch t.Action {
+	case "":
+		t.Action = "drop"
+	case "drop", "sample":
+	default:
+		return nil, fmt.Errorf("log_throttle: unsupported action %q", t.Action)
+	}
+	if t.SampleRate <= 0 {
+		t.SampleRate = 100
+	}
+	return t, nil
+}
+
+// GroupKeys are the record accessors the throttle filter counts lines by.
+func (t throttle) GroupKeys() string {
+	if t.GroupBy == "pod" {
+		return "$.kubernetes.namespace_name,$.kubernetes.pod_name"
+	}
+	return "$.kubernetes.namespace_name"
+}
+
+// GroupFields are the ECS names the group values are reported under.
+func (t throttle) GroupFields() string {
+	if t.GroupBy == "pod" {
+		return "kubernetes.namespace,kubernetes.pod.name"
+	}
+	return "kubernetes.namespace"
+}
+
+// pipeline is the data the aggregator and indexer configuration templates are rendered with.
+type pipeline struct {
+	Backend      logbackend.Kind
+	Archive      bool
+	Kafka        bool
+	Routes       []route
+	Throttle     *throttle
+	OTLP         bool
+	LogFormat    LogFormat
+	TailPatterns []ParsePattern
+	LogPatterns  []ParsePattern
+}
+
+// output is a single route together with the pipeline settings its match block needs.
+type output struct {
+	route
+	Backend logbackend.Kind
+	Archive bool
+	Kafka   bool
+}
+
+func (p pipeline) Outputs() []output {
+	outputs := make([]output, 0, len(p.Routes))
+	for _, r := range p.Routes {
+		outputs = append(outputs, output{
+			route:   r,
+			Backend: p.Backend,
+			Archive: p.Archive,
+			Kafka:   p.Kafka,
+		})
+	}
+	return outputs
+}
+
+// TopicOutputs keeps the first output of every Kafka topic, routes writing to the same
+// index share their topic and are indexed once.
+func (p pipeline) TopicOutputs() []output {
+	seen := map[string]bool{}
+	var outputs []output
+	for _, o := range p.Outputs() {
+		if !seen[o.Topic()] {
+			seen[o.Topic()] = true
+			outputs = append(outputs, o)
+		}
+	}
+	return outputs
+}
+
+// Topics lists the Kafka topics of every route, in the form kafka_group expects.
+func (p pipeline) Topics() string {
+	outputs := p.TopicOutputs()
+	topics := make([]string, 0, len(outputs))
+	for _, o := range outputs {
+		topics = append(topics, o.Topic())
+	}
+	return strings.Join(topics, ",")
+}
+
+func renderConfig(configTemplate string, p pipeline) (string, error) {
+	tmpl, err := template.ParseFiles(configTemplate, outputsTemplate)
+	if err != nil {
+		return "", err
+	}
+	var conf bytes.Buffer
+	if err = tmpl.Execute(&conf, p); err != nil {
+		return "", err
+	}
+	return conf.String(), nil
+}
@@ -1,6 +1,12 @@
 package fluentdlogging
 
 import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
 	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes"
 	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
 	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
@@ -8,11 +14,16 @@ import (
 	rbac "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/rbac/v1"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
-	"io/ioutil"
+	kafkabuffer "github.com/rodrigoafernandes/efk-cluster/kafka_buffer"
+	logarchive "github.com/rodrigoafernandes/efk-cluster/log_archive"
+	logbackend "github.com/rodrigoafernandes/efk-cluster/log_backend"
 )
 
+// pluginsDir is where the in-repo Fluentd plugins are mounted and loaded from.
+const pluginsDir = "/opt/bitnami/fluentd/custom-plugins"
+
 type FluentD interface {
-	ConfigureResources(*corev1.Namespace, *helm.Release) (pulumi.Resource, error)
+	ConfigureResources(*corev1.Namespace, pulumi.Resource, logbackend.Endpoint, *logarchive.Bucket, *kafkabuffer.Buffer) (pulumi.Resource, error)
 }
 
 type resource struct {
@@ -29,22 +40,89 @@ func NewFluentD(context *pulumi.Context, provider *kubernetes.Provider, cfg *con
 	}
 }
 
-func (f resource) ConfigureResources(namespace *corev1.Namespace, elasticSearch *helm.Release) (release pulumi.Resource, err error) {
-	fluentdConf, err := ioutil.ReadFile("fluentd_logging/fluentd.conf")
+func (f resource) ConfigureResources(namespace *corev1.Namespace, logStore pulumi.Resource, backend logbackend.Endpoint, archive *logarchive.Bucket, buffer *kafkabuffer.Buffer) (release pulumi.Resource, err error) {
+	logFormat := LogFormat(f.cfg.Get("container_log_format"))
+	if logFormat == "" {
+		logFormat = Auto
+	}
+	tailPatterns, err := TailPatterns(logFormat)
+	if err != nil {
+		return
+	}
+	sizing, err := configureAggregator(f.cfg)
+	if err != nil {
+		return
+	}
+	logThrottle, err := configureThrottle(f.cfg)
+	if err != nil {
+		return
+	}
+	// The collector pushes over the aggregator forward input, enabled with the same flag.
+	otlp := f.cfg.GetBool("otlp_ingest")
+	defaultRoutes := routes
+	if otlp {
+		defaultRoutes = append(append([]route{}, routes...), otlpRoute)
+	}
+	if logThrottle != nil {
+		defaultRoutes = append([]route{throttleRoute}, defaultRoutes...)
+	}
+	configuredRoutes, err := configureRoutes(f.cfg, defaultRoutes)
+	if err != nil {
+		return
+	}
+	p := pipeline{
+		Backend:      backend.Kind,
+		Archive:      archive != nil,
+		Kafka:        buffer != nil,
+		Routes:       configuredRoutes,
+		Throttle:     logThrottle,
+		OTLP:         otlp,
+		LogFormat:    logFormat,
+		TailPatterns: tailPatterns,
+		LogPatterns:  LogPatterns,
+	}
+	fluentdConf, err := renderConfig(aggregatorTemplate, p)
 	if err != nil {
 		return
 	}
+	plugin, err := os.ReadFile(throttlePlugin)
+	if err != nil {
+		return
+	}
+	configDependencies := []pulumi.Resource{logStore}
+	if archive != nil {
+		configDependencies = append(configDependencies, archive.Resource)
+	}
+	if buffer != nil {
+		indexer, err := f.createIndexer(namespace, p, backend, buffer, sizing.BufferSize)
+		if err != nil {
+			return nil, err
+		}
+		configDependencies = append(configDependencies, indexer)
+	}
 	esOutputConfigMap, err := corev1.NewConfigMap(f.ctx, "elasticsearch-output", &corev1.ConfigMapArgs{
 		Metadata: &metav1.ObjectMetaArgs{
 			Name:      pulumi.String("elasticsearch-output-cm"),
 			Namespace: namespace.Metadata.Name(),
 		},
 		Data: pulumi.StringMap{
-			"fluentd.conf": pulumi.String(fluentdConf[:]),
+			"fluentd.conf": pulumi.String(fluentdConf),
 		},
-	}, pulumi.Provider(f.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{elasticSearch}))
+	}, pulumi.Provider(f.provider), pulumi.Parent(namespace), pulumi.DependsOn(configDependencies))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("creating config map elasticsearch-output: %w", err)
+	}
+	pluginsConfigMap, err := corev1.NewConfigMap(f.ctx, "fluentd-plugins", &corev1.ConfigMapArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("fluentd-plugins-cm"),
+			Namespace: namespace.Metadata.Name(),
+		},
+		Data: pulumi.StringMap{
+			filepath.Base(throttlePlugin): pulumi.String(plugin),
+		},
+	}, pulumi.Provider(f.provider), pulumi.Parent(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("creating config map fluentd-plugins: %w", err)
 	}
 	clusterRole, err := rbac.NewClusterRole(f.ctx, "fluentd-aggregator-cr", &rbac.ClusterRoleArgs{
 		Metadata: &metav1.ObjectMetaArgs{
@@ -72,7 +150,7 @@ func (f resource) ConfigureResources(namespace *corev1.Namespace, elasticSearch
 		},
 	}, pulumi.Provider(f.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{esOutputConfigMap}))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("creating cluster role fluentd-aggregator-cr: %w", err)
 	}
 	aggregatorSa, err := corev1.NewServiceAccount(f.ctx, "fluentd-aggregator-sa", &corev1.ServiceAccountArgs{
 		Metadata: &metav1.ObjectMetaArgs{
@@ -87,7 +165,7 @@ func (f resource) ConfigureResources(namespace *corev1.Namespace, elasticSearch
 		AutomountServiceAccountToken: pulumi.Bool(true),
 	}, pulumi.Provider(f.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{clusterRole}))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("creating service account fluentd-aggregator-sa: %w", err)
 	}
 	crb, err := rbac.NewClusterRoleBinding(f.ctx, "fluentd-agrregator-crb", &rbac.ClusterRoleBindingArgs{
 		Metadata: &metav1.ObjectMetaArgs{
@@ -110,8 +188,50 @@ func (f resource) ConfigureResources(namespace *corev1.Namespace, elasticSearch
 			Name:     clusterRole.Metadata.Name().Elem(),
 		},
 	}, pulumi.Provider(f.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{aggregatorSa}))
-	elasticsearchHost := pulumi.String("elasticsearch.efk-logging.svc.cluster.local")
-	elasticsearchPort := pulumi.String("9200")
+	if err != nil {
+		return nil, fmt.Errorf("creating cluster role binding fluentd-aggregator-crb: %w", err)
+	}
+	extraEnv := backendEnv(backend)
+	if buffer != nil {
+		extraEnv = kafkaEnv(buffer)
+	}
+	if archive != nil {
+		extraEnv = append(extraEnv, archiveEnv(archive)...)
+	}
+	extraEnv = append(extraEnv, pulumi.Map{
+		"name":  pulumi.String("FLUENTD_OPT"),
+		"value": pulumi.String("-p " + pluginsDir),
+	})
+	aggregator := pulumi.Map{
+		"configMap": esOutputConfigMap.Metadata.Name(),
+		// The chart does not watch the ConfigMap, so a new checksum is what rolls the pods.
+		"podAnnotations": pulumi.Map{
+			"checksum/config":  pulumi.String(fmt.Sprintf("%x", sha256.Sum256([]byte(fluentdConf)))),
+			"checksum/plugins": pulumi.String(fmt.Sprintf("%x", sha256.Sum256(plugin))),
+		},
+		"extraEnv": extraEnv,
+		"extraVolumes": pulumi.MapArray{
+			pulumi.Map{
+				"name": pulumi.String("plugins"),
+				"configMap": pulumi.Map{
+					"name": pluginsConfigMap.Metadata.Name(),
+				},
+			},
+		},
+		"extraVolumeMounts": pulumi.MapArray{
+			pulumi.Map{
+				"name":      pulumi.String("plugins"),
+				"mountPath": pulumi.String(pluginsDir),
+				"readOnly":  pulumi.Bool(true),
+			},
+		},
+		"serviceAccount": pulumi.Map{
+			"name": aggregatorSa.Metadata.Name(),
+		},
+	}
+	for key, value := range sizing.values() {
+		aggregator[key] = value
+	}
 	release, err = helm.NewRelease(f.ctx, "fluentd", &helm.ReleaseArgs{
 		Name:      pulumi.String("fluentd"),
 		Namespace: namespace.Metadata.Name(),
@@ -121,33 +241,71 @@ func (f resource) ConfigureResources(namespace *corev1.Namespace, elasticSearch
 			Repo: pulumi.String("https://charts.bitnami.com/bitnami"),
 		},
 		Values: pulumi.Map{
-			"aggregator": pulumi.Map{
-				"configMap": esOutputConfigMap.Metadata.Name(),
-				"extraEnv": pulumi.MapArray{
-					pulumi.Map{
-						"name":  pulumi.String("ELASTICSEARCH_HOST"),
-						"value": elasticsearchHost,
-					},
-					pulumi.Map{
-						"name":  pulumi.String("ELASTICSEARCH_PORT"),
-						"value": elasticsearchPort,
-					},
-					pulumi.Map{
-						"name":  pulumi.String("ELASTICSEARCH_USER"),
-						"value": pulumi.String(f.cfg.Get("elasticsearch_user")),
-					},
-					pulumi.Map{
-						"name":  pulumi.String("ELASTICSEARCH_PASSWORD"),
-						"value": f.cfg.GetSecret("elasticsearch_pwd"),
-					},
-				},
-				"serviceAccount": pulumi.Map{
-					"name": aggregatorSa.Metadata.Name(),
-				},
-			},
+			"aggregator": aggregator,
 		},
-		Timeout: pulumi.Int(300),
-	}, pulumi.Provider(f.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{esOutputConfigMap, crb}))
+		Timeout: pulumi.Int(600),
+	}, pulumi.Provider(f.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{esOutputConfigMap, pluginsConfigMap, crb}))
+	if err != nil {
+		return nil, fmt.Errorf("creating release fluentd: %w", err)
+	}
+	return release, nil
+}
 
-	return
+func archiveEnv(archive *logarchive.Bucket) pulumi.MapArray {
+	return pulumi.MapArray{
+		pulumi.Map{
+			"name":  pulumi.String("S3_BUCKET"),
+			"value": archive.Name,
+		},
+		pulumi.Map{
+			"name":  pulumi.String("S3_ENDPOINT"),
+			"value": archive.Endpoint,
+		},
+		pulumi.Map{
+			"name":  pulumi.String("S3_REGION"),
+			"value": archive.Region,
+		},
+		pulumi.Map{
+			"name":  pulumi.String("S3_ACCESS_KEY"),
+			"value": archive.AccessKey,
+		},
+		pulumi.Map{
+			"name":  pulumi.String("S3_SECRET_KEY"),
+			"value": archive.SecretKey,
+		},
+	}
+}
+
+func backendEnv(backend logbackend.Endpoint) pulumi.MapArray {
+	return pulumi.MapArray{
+		pulumi.Map{
+			"name":  pulumi.String("LOG_BACKEND_SCHEME"),
+			"value": pulumi.String(backend.Scheme),
+		},
+		pulumi.Map{
+			"name":  pulumi.String("LOG_BACKEND_HOST"),
+			"value": pulumi.String(backend.Host),
+		},
+		pulumi.Map{
+			"name":  pulumi.String("LOG_BACKEND_PORT"),
+			"value": pulumi.String(strconv.Itoa(backend.Port)),
+		},
+		pulumi.Map{
+			"name":  pulumi.String("LOG_BACKEND_USER"),
+			"value": pulumi.String(backend.User),
+		},
+		pulumi.Map{
+			"name":  pulumi.String("LOG_BACKEND_PASSWORD"),
+			"value": backend.Password,
+		},
+	}
+}
+
+func kafkaEnv(buffer *kafkabuffer.Buffer) pulumi.MapArray {
+	return pulumi.MapArray{
+		pulumi.Map{
+			"name":  pulumi.String("KAFKA_BROKERS"),
+			"value": pulumi.String(buffer.Brokers),
+		},
+	}
 }
@@ -0,0 +1,158 @@
+package fluentdlogging
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	presource "github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+	logbackend "github.com/rodrigoafernandes/efk-cluster/log_backend"
+)
+
+// failingMocks records every resource that is created. The resource of type failType
+// named failName is rejected while it is registered, see failOn.
+type failingMocks struct {
+	failType string
+	failName string
+	mu       sync.Mutex
+	created  []string
+}
+
+func (m *failingMocks) NewResource(args pulumi.MockResourceArgs) (string, presource.PropertyMap, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.created = append(m.created, args.TypeToken+" "+args.Name)
+	return args.Name + "-id", args.Inputs, nil
+}
+
+func (m *failingMocks) Call(args pulumi.MockCallArgs) (presource.PropertyMap, error) {
+	return args.Args, nil
+}
+
+// failOn moves the failing resource under another parent, which the SDK refuses when it
+// registers the resource. The error is returned by the constructor, so it goes through
+// the component's own error handling as any invalid resource would.
+func (m *failingMocks) failOn(ctx *pulumi.Context) error {
+	if m.failType == "" {
+		return nil
+	}
+	parent, err := corev1.NewNamespace(ctx, "failure-parent", &corev1.NamespaceArgs{})
+	if err != nil {
+		return err
+	}
+	return ctx.RegisterStackTransformation(func(args *pulumi.ResourceTransformationArgs) *pulumi.ResourceTransformationResult {
+		if args.Type != m.failType || args.Name != m.failName {
+			return nil
+		}
+		return &pulumi.ResourceTransformationResult{Props: args.Props, Opts: append(args.Opts, pulumi.Parent(parent))}
+	})
+}
+
+// chdirRepo runs the test from the repository root, which the templates are relative to.
+func chdirRepo(t *testing.T) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(".."); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(dir) })
+}
+
+// runAggregator deploys the aggregator alone against mocks, as main.go does.
+func runAggregator(mocks *failingMocks) error {
+	return pulumi.RunErr(func(ctx *pulumi.Context) error {
+		if err := mocks.failOn(ctx); err != nil {
+			return err
+		}
+		provider, err := kubernetes.NewProvider(ctx, "k8s", &kubernetes.ProviderArgs{})
+		if err != nil {
+			return err
+		}
+		namespace, err := corev1.NewNamespace(ctx, "efk-namespace", &corev1.NamespaceArgs{}, pulumi.Provider(provider))
+		if err != nil {
+			return err
+		}
+		backend := logbackend.Endpoint{
+			Kind:     logbackend.Elasticsearch,
+			Scheme:   "http",
+			Host:     "elasticsearch.efk-logging.svc.cluster.local",
+			Port:     9200,
+			Password: pulumi.String("").ToStringOutput(),
+		}
+		fluentd := NewFluentD(ctx, provider, config.New(ctx, ""))
+		if _, err = fluentd.ConfigureResources(namespace, namespace, backend, nil, nil); err != nil {
+			return fmt.Errorf("fluentd: %w", err)
+		}
+		return nil
+	}, pulumi.WithMocks("efk-cluster", "test", mocks))
+}
+
+func TestAggregatorDeploys(t *testing.T) {
+	chdirRepo(t)
+	mocks := &failingMocks{}
+	if err := runAggregator(mocks); err != nil {
+		t.Fatal(err)
+	}
+	for _, created := range mocks.created {
+		if created == "kubernetes:helm.sh/v3:Release fluentd" {
+			return
+		}
+	}
+	t.Errorf("the fluentd release was not created: %v", mocks.created)
+}
+
+// TestFailedResourceStopsDeployment fails one resource of the aggregator at a time and
+// checks that the program returns the error of the component naming it and that nothing
+// depending on it is created.
+func TestFailedResourceStopsDeployment(t *testing.T) {
+	chdirRepo(t)
+	cases := []struct {
+		failType   string
+		failName   string
+		prefix     string
+		notCreated []string
+	}{
+		{"kubernetes:core/v1:ConfigMap", "elasticsearch-output", "fluentd: creating config map elasticsearch-output: ", []string{"kubernetes:rbac.authorization.k8s.io/v1:ClusterRole fluentd-aggregator-cr", "kubernetes:helm.sh/v3:Release fluentd"}},
+		{"kubernetes:rbac.authorization.k8s.io/v1:ClusterRoleBinding", "fluentd-agrregator-crb", "fluentd: creating cluster role binding fluentd-aggregator-crb: ", []string{"kubernetes:helm.sh/v3:Release fluentd"}},
+		{"kubernetes:helm.sh/v3:Release", "fluentd", "fluentd: creating release fluentd: ", []string{"kubernetes:helm.sh/v3:Release fluentd"}},
+	}
+	for _, c := range cases {
+		t.Run(c.failName, func(t *testing.T) {
+			mocks := &failingMocks{failType: c.failType, failName: c.failName}
+			err := runAggregator(mocks)
+			if err == nil || !strings.Contains(err.Error(), c.prefix) {
+				t.Fatalf("error is %v, want %q", err, c.prefix)
+			}
+			for _, created := range mocks.created {
+				for _, notCreated := range c.notCreated {
+					if created == notCreated {
+						t.Errorf("%s was created after %s failed", created, c.failName)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestInvalidConfigNamesComponent checks the errors returned before anything is
+// registered, which carry the component prefix main.go adds.
+func TestInvalidConfigNamesComponent(t *testing.T) {
+	chdirRepo(t)
+	t.Setenv("PULUMI_CONFIG", `{"efk-cluster:container_log_format": "journald"}`)
+	mocks := &failingMocks{}
+	err := runAggregator(mocks)
+	if err == nil || !strings.Contains(err.Error(), `fluentd: unsupported container log format "journald"`) {
+		t.Errorf("error is %v, want the fluentd component to reject the log format", err)
+	}
+	if len(mocks.created) > 2 {
+		t.Errorf("created %v after the config was rejected", mocks.created)
+	}
+}
@@ -0,0 +1,71 @@
+package fluentdlogging
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	kafkabuffer "github.com/rodrigoafernandes/efk-cluster/kafka_buffer"
+	logbackend "github.com/rodrigoafernandes/efk-cluster/log_backend"
+)
+
+// createIndexer installs a second Fluentd release that consumes the Kafka topics the
+// aggregators write to and indexes them into the log backend. The aggregators then keep
+// accepting logs while the backend is unavailable, as long as Kafka retains them. Offsets
+// are committed once events reach the file buffer, so the buffer is kept on a volume of
+// bufferSize, like the aggregator ones, to survive a restart during a backend outage.
+func (f resource) createIndexer(namespace *corev1.Namespace, p pipeline, backend logbackend.Endpoint, buffer *kafkabuffer.Buffer, bufferSize string) (pulumi.Resource, error) {
+	p.Kafka = false
+	p.Archive = false
+	indexerConf, err := renderConfig(indexerTemplate, p)
+	if err != nil {
+		return nil, err
+	}
+	configMap, err := corev1.NewConfigMap(f.ctx, "fluentd-indexer", &corev1.ConfigMapArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("fluentd-indexer-cm"),
+			Namespace: namespace.Metadata.Name(),
+		},
+		Data: pulumi.StringMap{
+			"fluentd.conf": pulumi.String(indexerConf),
+		},
+	}, pulumi.Provider(f.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{buffer.Resource}))
+	if err != nil {
+		return nil, fmt.Errorf("creating config map fluentd-indexer: %w", err)
+	}
+	release, err := helm.NewRelease(f.ctx, "fluentd-indexer", &helm.ReleaseArgs{
+		Name:      pulumi.String("fluentd-indexer"),
+		Namespace: namespace.Metadata.Name(),
+		Chart:     pulumi.String("fluentd"),
+		Version:   pulumi.String("5.5.12"),
+		RepositoryOpts: helm.RepositoryOptsArgs{
+			Repo: pulumi.String("https://charts.bitnami.com/bitnami"),
+		},
+		Values: pulumi.Map{
+			"forwarder": pulumi.Map{
+				"enabled": pulumi.Bool(false),
+			},
+			"aggregator": pulumi.Map{
+				"configMap": configMap.Metadata.Name(),
+				"podAnnotations": pulumi.Map{
+					"checksum/config": pulumi.String(fmt.Sprintf("%x", sha256.Sum256([]byte(indexerConf)))),
+				},
+				"extraEnv": append(backendEnv(backend), kafkaEnv(buffer)...),
+				"persistence": pulumi.Map{
+					"enabled":      pulumi.Bool(true),
+					"storageClass": pulumi.String("linode-block-storage"),
+					"accessModes":  pulumi.StringArray{pulumi.String("ReadWriteOnce")},
+					"size":         pulumi.String(bufferSize),
+				},
+			},
+		},
+		Timeout: pulumi.Int(300),
+	}, pulumi.Provider(f.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{configMap, buffer.Resource}))
+	if err != nil {
+		return nil, fmt.Errorf("creating release fluentd-indexer: %w", err)
+	}
+	return release, nil
+}
@@ -0,0 +1,249 @@
+package fluentdlogging
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ParsePattern is one <pattern> of a multi_format parser. Expression holds a Ruby
+// regular expression and is only set for the regexp format.
+type ParsePattern struct {
+	Name       string
+	Format     string
+	Expression string
+	TimeKey    string
+	TimeFormat string
+}
+
+// LogFormat is the format the container runtime writes its log files in.
+type LogFormat string
+
+const (
+	CRI        LogFormat = "cri"
+	DockerJSON LogFormat = "docker-json"
+	// Auto tries docker-json first and falls back to CRI, for node pools that mix runtimes.
+	Auto LogFormat = "auto"
+)
+
+var (
+	// CRIPattern reads the containerd/CRI-O text format: time, stream, a P(artial) or
+	// F(ull) tag and the message.
+	CRIPattern = ParsePattern{
+		Name:       "cri",
+		Format:     "regexp",
+		Expression: `^(?<time>[^ ]+) (?<stream>stdout|stderr) (?<logtag>[^ ]*) ?(?<log>.*)$`,
+		TimeFormat: "%Y-%m-%dT%H:%M:%S.%N%Z",
+	}
+	// DockerJSONPattern reads the json-file logging driver format used by dockershim nodes.
+	DockerJSONPattern = ParsePattern{
+		Name:       "docker-json",
+		Format:     "json",
+		TimeKey:    "time",
+		TimeFormat: "%Y-%m-%dT%H:%M:%S.%NZ",
+	}
+)
+
+// TailPatterns returns the patterns that parse the raw lines of the container log
+// files for the given format, first match wins.
+func TailPatterns(format LogFormat) ([]ParsePattern, error) {
+	switch format {
+	case CRI:
+		return []ParsePattern{CRIPattern}, nil
+	case DockerJSON:
+		return []ParsePattern{DockerJSONPattern}, nil
+	case Auto:
+		// A docker-json line is a JSON object, which CRI lines never are, while the CRI
+		// expression also matches a docker-json line whose log holds " stdout ".
+		return []ParsePattern{DockerJSONPattern, CRIPattern}, nil
+	}
+	return nil, fmt.Errorf("unsupported container log format %q", format)
+}
+
+// LogPatterns re-parse the log field extracted by TailPatterns.
+var LogPatterns = []ParsePattern{
+	{
+		Name:    "json",
+		Format:  "json",
+		TimeKey: "time",
+	},
+}
+
+// rubyNamedGroup matches the Ruby (?<name> syntax, which RE2 spells (?P<name>.
+var rubyNamedGroup = regexp.MustCompile(`\(\?<([A-Za-z_][A-Za-z0-9_]*)>`)
+
+// Extract applies the pattern to line the way Fluentd would and returns the extracted
+// fields. ok is false when the pattern does not match.
+func (p ParsePattern) Extract(line string) (fields map[string]interface{}, ok bool, err error) {
+	switch p.Format {
+	case "regexp":
+		expression, err := regexp.Compile(rubyNamedGroup.ReplaceAllString(p.Expression, "(?P<$1>"))
+		if err != nil {
+			return nil, false, fmt.Errorf("pattern %s: %w", p.Name, err)
+		}
+		match := expression.FindStringSubmatch(line)
+		if match == nil {
+			return nil, false, nil
+		}
+		fields = make(map[string]interface{})
+		for i, name := range expression.SubexpNames() {
+			if name != "" {
+				fields[name] = match[i]
+			}
+		}
+		return fields, true, nil
+	case "json":
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			return nil, false, nil
+		}
+		return fields, true, nil
+	}
+	return nil, false, fmt.Errorf("pattern %s: unsupported format %q", p.Name, p.Format)
+}
+
+// Result reports how a single fixture line went through the parse stages.
+type Result struct {
+	Fixture     string
+	Line        int
+	TailPattern string
+	LogPattern  string
+	Fields      map[string]interface{}
+	// Partial is set for a P(artial) line the file never completed. Concat flushes it to
+	// the error stream once its flush_interval expires.
+	Partial bool
+}
+
+// Matched tells whether the tail source would have accepted the line at all.
+func (r Result) Matched() bool {
+	return r.TailPattern != ""
+}
+
+// Dropped tells why the pipeline would discard the line, or "" when it reaches the outputs.
+// The parser filter keeps no data of the records it fails on, so a log field no log
+// pattern accepts drops the whole line.
+func (r Result) Dropped() string {
+	switch {
+	case !r.Matched():
+		return "no tail pattern matched"
+	case r.Partial:
+		return "partial line never completed"
+	case r.LogPattern == "":
+		return "no log pattern matched"
+	}
+	return ""
+}
+
+// ParseFixtures runs every line of the *.log files in dir through the tail patterns of
+// format, joins partial lines and, when the line yields a log field, runs it through
+// LogPatterns, mirroring the aggregator pipeline. Partial lines still open at the end of
+// a file are reported last.
+func ParseFixtures(dir string, format LogFormat) ([]Result, error) {
+	tailPatterns, err := TailPatterns(format)
+	if err != nil {
+		return nil, err
+	}
+	fixtures, err := filepath.Glob(filepath.Join(dir, "*.log"))
+	if err != nil {
+		return nil, err
+	}
+	var results []Result
+	for _, fixture := range fixtures {
+		fixtureResults, err := parseFixture(fixture, tailPatterns)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, fixtureResults...)
+	}
+	return results, nil
+}
+
+func parseFixture(fixture string, tailPatterns []ParsePattern) ([]Result, error) {
+	file, err := os.Open(fixture)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var results []Result
+	// partial keeps lines tagged P per stream until the closing F line arrives. Docker
+	// lines are partial when their log does not end with a newline.
+	partial := make(map[interface{}]*Result)
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		result := Result{
+			Fixture: filepath.Base(fixture),
+			Line:    lineNumber,
+		}
+		result.TailPattern, result.Fields, err = firstMatch(tailPatterns, line)
+		if err != nil {
+			return nil, err
+		}
+		if result.TailPattern == DockerJSONPattern.Name {
+			result.Fields["logtag"] = "P"
+			if strings.HasSuffix(fmt.Sprint(result.Fields["log"]), "\n") {
+				result.Fields["logtag"] = "F"
+			}
+		}
+		stream := result.Fields["stream"]
+		if pending, ok := partial[stream]; ok {
+			pending.Fields["log"] = fmt.Sprint(pending.Fields["log"], result.Fields["log"])
+			pending.Fields["logtag"] = result.Fields["logtag"]
+			result = *pending
+			delete(partial, stream)
+		}
+		if result.Fields["logtag"] == "P" {
+			partial[stream] = &result
+			continue
+		}
+		if err = parseLog(&result); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	var pending []Result
+	for _, result := range partial {
+		result.Partial = true
+		pending = append(pending, *result)
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Line < pending[j].Line })
+	return append(results, pending...), nil
+}
+
+func parseLog(result *Result) error {
+	log, isString := result.Fields["log"].(string)
+	if !isString {
+		return nil
+	}
+	logPattern, logFields, err := firstMatch(LogPatterns, log)
+	if err != nil {
+		return err
+	}
+	if logPattern != "" {
+		result.LogPattern, result.Fields = logPattern, logFields
+	}
+	return nil
+}
+
+func firstMatch(patterns []ParsePattern, line string) (string, map[string]interface{}, error) {
+	for _, pattern := range patterns {
+		fields, ok, err := pattern.Extract(line)
+		if err != nil {
+			return "", nil, err
+		}
+		if ok {
+			return pattern.Name, fields, nil
+		}
+	}
+	return "", nil, nil
+}
@@ -0,0 +1,113 @@
+package fluentdlogging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseFixtures(t *testing.T) {
+	results, err := ParseFixtures("testdata", Auto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		fixture     string
+		line        int
+		tailPattern string
+		logPattern  string
+		message     string
+		dropped     string
+	}{
+		{"cri.log", 1, "cri", "json", "Listening on: http://0.0.0.0:8080", ""},
+		{"cri.log", 2, "cri", "", "", "no log pattern matched"},
+		{"cri.log", 3, "cri", "json", "first half of a line longer than the runtime buffer and its second half", ""},
+		{"cri.log", 5, "cri", "json", "", ""},
+		{"cri.log", 6, "cri", "", "", "no log pattern matched"},
+		{"docker-json.log", 1, "docker-json", "json", "Listening on: http://0.0.0.0:8080", ""},
+		{"docker-json.log", 2, "docker-json", "", "", "no log pattern matched"},
+		{"docker-json.log", 3, "docker-json", "json", "first half of a line longer than 16K and its second half", ""},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d: %+v", len(results), len(want), results)
+	}
+	for i, w := range want {
+		r := results[i]
+		if r.Fixture != w.fixture || r.Line != w.line {
+			t.Errorf("result %d is %s:%d, want %s:%d", i, r.Fixture, r.Line, w.fixture, w.line)
+			continue
+		}
+		if r.TailPattern != w.tailPattern || r.LogPattern != w.logPattern {
+			t.Errorf("%s:%d matched %q/%q, want %q/%q", r.Fixture, r.Line, r.TailPattern, r.LogPattern, w.tailPattern, w.logPattern)
+		}
+		if w.message != "" && r.Fields["message"] != w.message {
+			t.Errorf("%s:%d message is %q, want %q", r.Fixture, r.Line, r.Fields["message"], w.message)
+		}
+		if r.Dropped() != w.dropped {
+			t.Errorf("%s:%d dropped is %q, want %q", r.Fixture, r.Line, r.Dropped(), w.dropped)
+		}
+	}
+}
+
+func TestParseFixturesSingleFormat(t *testing.T) {
+	for _, format := range []LogFormat{CRI, DockerJSON} {
+		results, err := ParseFixtures("testdata", format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range results {
+			native := r.Fixture == string(format)+".log"
+			if r.Matched() != native {
+				t.Errorf("%s: %s:%d matched is %t, want %t", format, r.Fixture, r.Line, r.Matched(), native)
+			}
+		}
+	}
+}
+
+func TestParseFixturesPartialAtEOF(t *testing.T) {
+	dir := t.TempDir()
+	fixture := "2023-01-18T14:03:13.000000000Z stdout F {\"message\":\"complete\"}\n" +
+		"2023-01-18T14:03:14.000000000Z stdout P {\"message\":\"cut\n"
+	if err := os.WriteFile(filepath.Join(dir, "truncated.log"), []byte(fixture), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	results, err := ParseFixtures(dir, CRI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want the complete line and the pending one: %+v", len(results), results)
+	}
+	if results[0].Dropped() != "" {
+		t.Errorf("line 1 dropped: %s", results[0].Dropped())
+	}
+	pending := results[1]
+	if pending.Line != 2 || !pending.Partial || pending.Dropped() != "partial line never completed" {
+		t.Errorf("line 2 is %+v, want it reported as a partial line never completed", pending)
+	}
+}
+
+func TestParseAutoDockerJSONLineWithStream(t *testing.T) {
+	dir := t.TempDir()
+	line := `{"log":"{\"level\":\"INFO\",\"message\":\"copied stdout and stderr to the log file\"}\n","stream":"stderr","time":"2023-01-18T14:03:14.000000000Z"}`
+	if _, ok, _ := CRIPattern.Extract(line); !ok {
+		t.Fatal("the CRI expression does not match the line, the fixture no longer covers the ambiguity")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "mixed.log"), []byte(line+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	results, err := ParseFixtures(dir, Auto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1: %+v", len(results), results)
+	}
+	r := results[0]
+	if r.TailPattern != "docker-json" || r.LogPattern != "json" {
+		t.Errorf("parsed with %s then %s, want docker-json then json", r.TailPattern, r.LogPattern)
+	}
+	if r.Fields["message"] != "copied stdout and stderr to the log file" {
+		t.Errorf("fields are %v", r.Fields)
+	}
+}
@@ -0,0 +1,85 @@
+require 'fluent/plugin/filter'
+require 'time'
+
+module Fluent
+  module Plugin
+    # Limits how many events a group (a namespace, a pod...) may send per second. Events
+    # above the limit are dropped, or sampled, and every window that dropped something is
+    # reported as its own event, so floods show up in the backend instead of vanishing.
+    class GroupThrottleFilter < Filter
+      Fluent::Plugin.register_filter('group_throttle', self)
+
+      helpers :record_accessor, :timer, :event_emitter
+
+      desc 'Record accessor expressions whose values identify the group of an event'
+      config_param :group_keys, :array, value_type: :string, default: ['$.kubernetes.namespace_name']
+      desc 'Field names the group values are reported under, one per group key'
+      config_param :group_fields, :array, value_type: :string, default: ['kubernetes.namespace']
+      desc 'Events per second a group may send before it is throttled'
+      config_param :rate_limit, :integer, default: 1000
+      desc 'Window the rate is measured over'
+      config_param :window, :time, default: 10
+      desc 'drop discards every event above the limit, sample keeps one of every sample_rate'
+      config_param :action, :enum, list: [:drop, :sample], default: :drop
+      config_param :sample_rate, :integer, default: 100
+      desc 'Tag of the events reporting dropped lines'
+      config_param :report_tag, :string, default: 'fluentd.throttle'
+
+      def configure(conf)
+        super
+        raise Fluent::ConfigError, 'group_fields needs one name per group key' if @group_fields.size != @group_keys.size
+        @accessors = @group_keys.map { |key| record_accessor_create(key) }
+        @limit = @rate_limit * @window
+        @groups = {}
+        @mutex = Mutex.new
+      end
+
+      def start
+        super
+        timer_execute(:group_throttle_report, @window) { report }
+      end
+
+      def filter(_tag, _time, record)
+        group = @accessors.map { |accessor| accessor.call(record) }
+        @mutex.synchronize do
+          counter = (@groups[group] ||= { received: 0, dropped: 0 })
+          counter[:received] += 1
+          over = counter[:received] - @limit
+          if over <= 0 || (@action == :sample && (over % @sample_rate).zero?)
+            record
+          else
+            counter[:dropped] += 1
+            nil
+          end
+        end
+      end
+
+      private
+
+      # Closes the current window: reports the groups that lost events and starts counting again.
+      def report
+        groups = @mutex.synchronize do
+          current = @groups
+          @groups = {}
+          current
+        end
+        now = Fluent::EventTime.now
+        groups.each do |group, counter|
+          next if counter[:dropped].zero?
+          event = @group_fields.zip(group).to_h.merge(
+            '@timestamp' => Time.at(now.to_r).utc.iso8601(3),
+            'message' => "throttled #{group.join('/')}: dropped #{counter[:dropped]} of #{counter[:received]} lines in #{@window.to_i}s",
+            'log.level' => 'warn',
+            'event.dataset' => @report_tag,
+            'throttle.received' => counter[:received],
+            'throttle.dropped' => counter[:dropped],
+            'throttle.limit_per_second' => @rate_limit,
+            'throttle.window_seconds' => @window.to_i,
+            'throttle.action' => @action.to_s
+          )
+          router.emit(@report_tag, now, event)
+        end
+      end
+    end
+  end
+end
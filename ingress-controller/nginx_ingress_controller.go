@@ -10,6 +10,16 @@ import (
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
 
+// accessLogFormat writes one JSON document per request. Numeric fields are left unquoted
+// so they are indexed as numbers; upstream fields may hold lists or "-" and stay strings.
+const accessLogFormat = `{"time": "$time_iso8601", "request_id": "$req_id", "remote_addr": "$remote_addr", ` +
+	`"host": "$host", "method": "$request_method", "path": "$uri", "query": "$args", ` +
+	`"protocol": "$server_protocol", "status": $status, "bytes_sent": $bytes_sent, ` +
+	`"request_time": $request_time, "upstream": "$upstream_addr", "upstream_status": "$upstream_status", ` +
+	`"upstream_response_time": "$upstream_response_time", "ingress": "$ingress_name", ` +
+	`"namespace": "$namespace", "service": "$service_name", "user_agent": "$http_user_agent", ` +
+	`"referer": "$http_referer"}`
+
 type NginxIngressController struct {
 	ctx      *pulumi.Context
 	provider *kubernetes.Provider
@@ -26,7 +36,7 @@ func (n *NginxIngressController) CreateResources(parent pulumi.Resource) (*helm.
 	}, pulumi.Provider(n.provider), pulumi.DependsOn([]pulumi.Resource{parent}))
 
 	if err != nil {
-		return nil, pulumi.String("").ToStringOutput(), err
+		return nil, pulumi.String("").ToStringOutput(), fmt.Errorf("creating namespace nginx-ingress-namespace: %w", err)
 	}
 
 	rel, err := helm.NewRelease(n.ctx, "nginx-ingress", &helm.ReleaseArgs{
@@ -36,11 +46,19 @@ func (n *NginxIngressController) CreateResources(parent pulumi.Resource) (*helm.
 		RepositoryOpts: helm.RepositoryOptsArgs{
 			Repo: pulumi.String("https://kubernetes.github.io/ingress-nginx"),
 		},
+		Values: pulumi.Map{
+			"controller": pulumi.Map{
+				"config": pulumi.Map{
+					"log-format-escape-json": pulumi.String("true"),
+					"log-format-upstream":    pulumi.String(accessLogFormat),
+				},
+			},
+		},
 		Timeout: pulumi.Int(120),
 	}, pulumi.Provider(n.provider), pulumi.Parent(namespace))
 
 	if err != nil {
-		return nil, pulumi.String("").ToStringOutput(), err
+		return nil, pulumi.String("").ToStringOutput(), fmt.Errorf("creating release nginx-ingress: %w", err)
 	}
 
 	hostName := pulumi.All(rel.Status.Namespace(), rel.Status.Name()).
@@ -54,10 +72,10 @@ func (n *NginxIngressController) CreateResources(parent pulumi.Resource) (*helm.
 				nil,
 				pulumi.Provider(n.provider), pulumi.Parent(namespace))
 			if err != nil {
-				return pulumi.String("").ToStringOutput(), err
+				return pulumi.String("").ToStringOutput(), fmt.Errorf("reading service ingress-nginx-controller-svc: %w", err)
 			}
 			return svc.Status.LoadBalancer().Ingress().Index(pulumi.Int(0)).Hostname().Elem().ToStringOutput(), nil
 		}).(pulumi.StringOutput)
 
-	return rel, hostName, err
+	return rel, hostName, nil
 }
@@ -0,0 +1,60 @@
+package ingresscontroller
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LoadBalancerHost stands for the hostname of the ingress load balancer, which is only
+// known once it is provisioned, in the declared Routes.
+const LoadBalancerHost = "<load-balancer>"
+
+// Route is a host and path of an Ingress created by the program.
+type Route struct {
+	Ingress string
+	Host    string
+	Path    string
+}
+
+// Routes are the Ingress rules of the program. Every component declares its own before
+// anything is created, so a collision fails the program before any Ingress is deployed.
+type Routes []Route
+
+// Validate fails when two Ingresses claim the same path on the same host, or when a
+// pattern such as "/*" covers the path of another Ingress. nginx serves such paths from
+// whichever location it orders first, so one backend would silently lose part of its
+// traffic. A plain prefix may contain others, nginx prefers the longest one.
+func (routes Routes) Validate() error {
+	var collisions []string
+	for i, a := range routes {
+		for _, b := range routes[i+1:] {
+			if a.Host == b.Host && a.Ingress != b.Ingress && (collides(a.Path, b.Path) || collides(b.Path, a.Path)) {
+				collisions = append(collisions, fmt.Sprintf("%s %s and %s %s on %s", a.Ingress, a.Path, b.Ingress, b.Path, a.Host))
+			}
+		}
+	}
+	if len(collisions) > 0 {
+		return fmt.Errorf("ingress paths collide: %s", strings.Join(collisions, "; "))
+	}
+	return nil
+}
+
+// collides tells whether outer takes requests meant for inner.
+func collides(outer, inner string) bool {
+	outerPrefix, innerPrefix := literalPrefix(outer), literalPrefix(inner)
+	if outerPrefix == innerPrefix {
+		return true
+	}
+	isPattern := outerPrefix != strings.TrimRight(outer, "/")
+	return isPattern && (outerPrefix == "" || strings.HasPrefix(innerPrefix, outerPrefix+"/"))
+}
+
+// literalPrefix is the part of a path before its first regular expression construct,
+// without the trailing slash: "/alura-languages(/|$)(.*)" gives "/alura-languages" and
+// "/*" gives "", which matches every path.
+func literalPrefix(path string) string {
+	if i := strings.IndexAny(path, `*([?+|^$\{`); i >= 0 {
+		path = path[:i]
+	}
+	return strings.TrimRight(path, "/")
+}
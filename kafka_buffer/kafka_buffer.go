@@ -0,0 +1,83 @@
+package kafkabuffer
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+const defaultRetentionHours = 72
+
+// KafkaBuffer is a durable queue between the Fluentd aggregators and the log backend,
+// sized to hold the logs produced while the backend is down for hours.
+type KafkaBuffer interface {
+	CreateResources(namespace *corev1.Namespace, dependsOn ...pulumi.Resource) (*Buffer, error)
+}
+
+// Buffer tells producers and consumers how to reach the brokers.
+type Buffer struct {
+	Brokers  string
+	Resource pulumi.Resource
+}
+
+type resource struct {
+	ctx      *pulumi.Context
+	provider *kubernetes.Provider
+	cfg      *config.Config
+}
+
+// NewKafkaBuffer returns the Kafka tier when "kafka_buffer" is enabled, nil otherwise.
+func NewKafkaBuffer(ctx *pulumi.Context, provider *kubernetes.Provider, cfg *config.Config) KafkaBuffer {
+	if !cfg.GetBool("kafka_buffer") {
+		return nil
+	}
+	return resource{
+		ctx:      ctx,
+		provider: provider,
+		cfg:      cfg,
+	}
+}
+
+func (k resource) CreateResources(namespace *corev1.Namespace, dependsOn ...pulumi.Resource) (*Buffer, error) {
+	retentionHours := k.cfg.GetInt("kafka_retention_hours")
+	if retentionHours <= 0 {
+		retentionHours = defaultRetentionHours
+	}
+	// Topics are created on first write, one per Fluentd route, with the defaults below.
+	release, err := helm.NewRelease(k.ctx, "kafka", &helm.ReleaseArgs{
+		Name:      pulumi.String("kafka"),
+		Namespace: namespace.Metadata.Name(),
+		Chart:     pulumi.String("kafka"),
+		Version:   pulumi.String("20.0.6"),
+		RepositoryOpts: helm.RepositoryOptsArgs{
+			Repo: pulumi.String("https://charts.bitnami.com/bitnami"),
+		},
+		Values: pulumi.Map{
+			"global": pulumi.Map{
+				"storageClass": pulumi.String("linode-block-storage"),
+			},
+			"replicaCount":                         pulumi.Int(3),
+			"autoCreateTopicsEnable":               pulumi.Bool(true),
+			"numPartitions":                        pulumi.Int(6),
+			"defaultReplicationFactor":             pulumi.Int(3),
+			"offsetsTopicReplicationFactor":        pulumi.Int(3),
+			"transactionStateLogReplicationFactor": pulumi.Int(3),
+			"logRetentionHours":                    pulumi.Int(retentionHours),
+			"persistence": pulumi.Map{
+				"size": pulumi.String("20Gi"),
+			},
+		},
+		Timeout: pulumi.Int(600),
+	}, pulumi.Provider(k.provider), pulumi.Parent(namespace), pulumi.DependsOn(dependsOn))
+	if err != nil {
+		return nil, fmt.Errorf("creating release kafka: %w", err)
+	}
+	return &Buffer{
+		Brokers:  "kafka.efk-logging.svc.cluster.local:9092",
+		Resource: release,
+	}, nil
+}
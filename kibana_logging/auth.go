@@ -0,0 +1,337 @@
+package kibanalogging
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/meta/v1"
+	networkingv1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/networking/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Values of "kibana_auth".
+const (
+	NoAuth     = ""
+	BasicAuth  = "basic"
+	OAuth2Auth = "oauth2"
+)
+
+const (
+	// dexIssuer is only reachable inside the cluster, oauth2-proxy talks to it directly.
+	dexIssuer = "http://dex.efk-logging.svc.cluster.local:5556/dex"
+	// dexLoginURL is where browsers are sent to sign in, through
+	// kubectl -n efk-logging port-forward svc/dex 5556.
+	dexLoginURL = "http://localhost:5556/dex/auth"
+)
+
+// createAuth puts the authentication selected by "kibana_auth" in front of the Kibana
+// ingress and returns the annotations that enable it. kibanaHost is the hostname Kibana
+// is served on.
+func (k resource) createAuth(namespace *corev1.Namespace, kibanaHost pulumi.StringOutput) (pulumi.StringMap, []pulumi.Resource, error) {
+	switch mode := k.cfg.Get("kibana_auth"); mode {
+	case NoAuth:
+		return pulumi.StringMap{}, nil, nil
+	case BasicAuth:
+		return k.createBasicAuth(namespace)
+	case OAuth2Auth:
+		return k.createOAuth2Proxy(namespace, kibanaHost)
+	default:
+		return nil, nil, fmt.Errorf("kibana_auth %q is not one of basic, oauth2", mode)
+	}
+}
+
+// createBasicAuth stores the "kibana_auth_user" credentials in the htpasswd format nginx
+// reads. The salt is drawn once and kept in the state, so the Secret only changes when the
+// credentials do.
+func (k resource) createBasicAuth(namespace *corev1.Namespace) (pulumi.StringMap, []pulumi.Resource, error) {
+	user := k.cfg.Get("kibana_auth_user")
+	if user == "" {
+		return nil, nil, fmt.Errorf("kibana_auth basic needs kibana_auth_user and kibana_auth_pwd")
+	}
+	salt := make([]byte, 8)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, fmt.Errorf("drawing the kibana_auth_pwd salt: %w", err)
+	}
+	saltSecret, storedSalt, err := k.createStoredValue(namespace, "kibana-basic-auth-salt", pulumi.String(salt).ToStringOutput())
+	if err != nil {
+		return nil, nil, err
+	}
+	htpasswd := pulumi.All(k.cfg.GetSecret("kibana_auth_pwd"), storedSalt).ApplyT(func(args []interface{}) string {
+		return htpasswdLine(user, args[0].(string), []byte(args[1].(string)))
+	}).(pulumi.StringOutput)
+	secret, err := corev1.NewSecret(k.ctx, "kibana-basic-auth", &corev1.SecretArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("kibana-basic-auth"),
+			Namespace: namespace.Metadata.Name(),
+		},
+		Type: pulumi.String("Opaque"),
+		StringData: pulumi.StringMap{
+			"auth": htpasswd,
+		},
+	}, pulumi.Provider(k.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{saltSecret}))
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating secret kibana-basic-auth: %w", err)
+	}
+	return pulumi.StringMap{
+		"nginx.ingress.kubernetes.io/auth-type":   pulumi.String("basic"),
+		"nginx.ingress.kubernetes.io/auth-secret": secret.Metadata.Name().Elem(),
+		"nginx.ingress.kubernetes.io/auth-realm":  pulumi.String("Kibana"),
+	}, []pulumi.Resource{secret}, nil
+}
+
+// htpasswdLine hashes password with salted SHA-1, the {SSHA} scheme nginx supports natively.
+func htpasswdLine(user, password string, salt []byte) string {
+	hash := sha1.Sum(append([]byte(password), salt...))
+	return fmt.Sprintf("%s:{SSHA}%s\n", user, base64.StdEncoding.EncodeToString(append(hash[:], salt...)))
+}
+
+// createStoredValue keeps value in a Secret whose later changes are ignored, and returns
+// the value of the first run as read back from the state. It pins values drawn at random,
+// such as salts, that would otherwise change the resources using them on every run.
+func (k resource) createStoredValue(namespace *corev1.Namespace, name string, value pulumi.StringOutput) (*corev1.Secret, pulumi.StringOutput, error) {
+	secret, err := corev1.NewSecret(k.ctx, name, &corev1.SecretArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(name),
+			Namespace: namespace.Metadata.Name(),
+		},
+		Type: pulumi.String("Opaque"),
+		Data: pulumi.StringMap{
+			"value": value.ApplyT(func(v string) string {
+				return base64.StdEncoding.EncodeToString([]byte(v))
+			}).(pulumi.StringOutput),
+		},
+	}, pulumi.Provider(k.provider), pulumi.Parent(namespace), pulumi.IgnoreChanges([]string{"data"}))
+	if err != nil {
+		return nil, pulumi.StringOutput{}, fmt.Errorf("creating secret %s: %w", name, err)
+	}
+	stored := secret.Data.MapIndex(pulumi.String("value")).ApplyT(func(encoded string) (string, error) {
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", fmt.Errorf("secret %s: %w", name, err)
+		}
+		return string(decoded), nil
+	}).(pulumi.StringOutput)
+	return secret, pulumi.ToSecret(stored).(pulumi.StringOutput), nil
+}
+
+// oauth2EmailDomains reads the "kibana_oauth2_email_domains" list of the domains whose
+// users may sign in, e.g. ["example.com"]. There is no default, any account of the
+// provider would otherwise be let in.
+func (k resource) oauth2EmailDomains() (string, error) {
+	var domains []string
+	if err := k.cfg.GetObject("kibana_oauth2_email_domains", &domains); err != nil {
+		return "", fmt.Errorf("kibana_oauth2_email_domains: %w", err)
+	}
+	if len(domains) == 0 {
+		return "", fmt.Errorf("kibana_auth oauth2 needs kibana_oauth2_email_domains")
+	}
+	quoted := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		if domain == "*" {
+			return "", fmt.Errorf("kibana_oauth2_email_domains: \"*\" would let any account sign in")
+		}
+		quoted = append(quoted, fmt.Sprintf("%q", domain))
+	}
+	return "[" + strings.Join(quoted, ", ") + "]", nil
+}
+
+// checkOAuth2Secrets rejects the secrets oauth2-proxy would only refuse once running. The
+// cookie secret is an AES key: 16, 24 or 32 bytes, given as is or base64 encoded.
+func (k resource) checkOAuth2Secrets() error {
+	if k.cfg.Get("kibana_oidc_client_secret") == "" {
+		return fmt.Errorf("kibana_auth oauth2 needs kibana_oidc_client_secret")
+	}
+	cookieSecret := k.cfg.Get("kibana_oauth2_cookie_secret")
+	validLength := func(n int) bool { return n == 16 || n == 24 || n == 32 }
+	if validLength(len(cookieSecret)) {
+		return nil
+	}
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if key, err := encoding.DecodeString(cookieSecret); err == nil && validLength(len(key)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("kibana_oauth2_cookie_secret must be 16, 24 or 32 bytes, or their base64 encoding")
+}
+
+// createOAuth2Proxy authenticates Kibana users against the OIDC provider at
+// "kibana_oidc_issuer_url" through oauth2-proxy, which nginx asks about every request.
+// With "kibana_oidc_dex" a Dex stand-in is deployed and used as the provider.
+func (k resource) createOAuth2Proxy(namespace *corev1.Namespace, kibanaHost pulumi.StringOutput) (pulumi.StringMap, []pulumi.Resource, error) {
+	emailDomains, err := k.oauth2EmailDomains()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = k.checkOAuth2Secrets(); err != nil {
+		return nil, nil, err
+	}
+	clientID := k.cfg.Get("kibana_oidc_client_id")
+	if clientID == "" {
+		clientID = "kibana"
+	}
+	clientSecret := k.cfg.GetSecret("kibana_oidc_client_secret")
+	issuer := pulumi.String(k.cfg.Get("kibana_oidc_issuer_url")).ToStringOutput()
+	redirectURL := pulumi.Sprintf("http://%s/oauth2/callback", kibanaHost)
+	extraArgs := pulumi.Map{
+		"provider":             pulumi.String("oidc"),
+		"redirect-url":         redirectURL,
+		"reverse-proxy":        pulumi.Bool(true),
+		"skip-provider-button": pulumi.Bool(true),
+		"set-xauthrequest":     pulumi.Bool(true),
+		// The load balancer serves plain HTTP.
+		"cookie-secure": pulumi.Bool(false),
+	}
+	var dependencies []pulumi.Resource
+	if k.cfg.GetBool("kibana_oidc_dex") {
+		dex, err := k.createDex(namespace, clientID, clientSecret, redirectURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		issuer = pulumi.String(dexIssuer).ToStringOutput()
+		// Dex is not published, so the endpoints are set explicitly: oauth2-proxy uses the
+		// in-cluster ones, browsers sign in through a port-forward.
+		extraArgs["skip-oidc-discovery"] = pulumi.Bool(true)
+		extraArgs["login-url"] = pulumi.String(dexLoginURL)
+		extraArgs["redeem-url"] = pulumi.String(dexIssuer + "/token")
+		extraArgs["oidc-jwks-url"] = pulumi.String(dexIssuer + "/keys")
+		dependencies = append(dependencies, dex)
+	} else if k.cfg.Get("kibana_oidc_issuer_url") == "" {
+		return nil, nil, fmt.Errorf("kibana_auth oauth2 needs kibana_oidc_issuer_url or kibana_oidc_dex")
+	}
+	extraArgs["oidc-issuer-url"] = issuer
+	proxy, err := helm.NewRelease(k.ctx, "oauth2-proxy", &helm.ReleaseArgs{
+		Name:      pulumi.String("oauth2-proxy"),
+		Namespace: namespace.Metadata.Name(),
+		Chart:     pulumi.String("oauth2-proxy"),
+		Version:   pulumi.String("6.8.0"),
+		RepositoryOpts: helm.RepositoryOptsArgs{
+			Repo: pulumi.String("https://oauth2-proxy.github.io/manifests"),
+		},
+		Values: pulumi.Map{
+			"config": pulumi.Map{
+				"clientID":     pulumi.String(clientID),
+				"clientSecret": clientSecret,
+				"cookieSecret": k.cfg.GetSecret("kibana_oauth2_cookie_secret"),
+				"configFile":   pulumi.String(fmt.Sprintf("email_domains = %s\nupstreams = [\"static://202\"]", emailDomains)),
+			},
+			"extraArgs": extraArgs,
+		},
+		Timeout: pulumi.Int(300),
+	}, pulumi.Provider(k.provider), pulumi.Parent(namespace), pulumi.DependsOn(dependencies))
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating release oauth2-proxy: %w", err)
+	}
+	ingress, err := networkingv1.NewIngress(k.ctx, "oauth2-proxy-ingress", &networkingv1.IngressArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("oauth2-proxy-ingress"),
+			Namespace: namespace.Metadata.Name(),
+			Annotations: pulumi.StringMap{
+				"kubernetes.io/ingress.class": pulumi.String("nginx"),
+			},
+		},
+		Spec: networkingv1.IngressSpecArgs{
+			Rules: networkingv1.IngressRuleArray{
+				ingressRule(kibanaHost, "/oauth2", "oauth2-proxy", 80),
+			},
+		},
+	}, pulumi.Provider(k.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{proxy}))
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating ingress oauth2-proxy-ingress: %w", err)
+	}
+	return pulumi.StringMap{
+		"nginx.ingress.kubernetes.io/auth-url":              pulumi.String("http://oauth2-proxy.efk-logging.svc.cluster.local/oauth2/auth"),
+		"nginx.ingress.kubernetes.io/auth-signin":           pulumi.String("http://$host/oauth2/start?rd=$escaped_request_uri"),
+		"nginx.ingress.kubernetes.io/auth-response-headers": pulumi.String("X-Auth-Request-User,X-Auth-Request-Email"),
+	}, []pulumi.Resource{proxy, ingress}, nil
+}
+
+// createDex deploys an in-memory Dex with a single admin@example.com user, enough to try
+// the OIDC login without a real identity provider. Its password is "kibana_dex_pwd";
+// bcrypt salts anew on every run, so the hash is kept in the state, in a Secret named
+// after a checksum of the password: changing the password creates a new hash.
+func (k resource) createDex(namespace *corev1.Namespace, clientID string, clientSecret pulumi.StringOutput, redirectURL pulumi.StringOutput) (pulumi.Resource, error) {
+	password, err := k.cfg.TrySecret("kibana_dex_pwd")
+	if err != nil {
+		return nil, fmt.Errorf("kibana_oidc_dex needs kibana_dex_pwd")
+	}
+	hash := password.ApplyT(func(password string) (string, error) {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), 10)
+		if err != nil {
+			return "", fmt.Errorf("hashing kibana_dex_pwd: %w", err)
+		}
+		return string(hash), nil
+	}).(pulumi.StringOutput)
+	sum := sha256.Sum256([]byte("dex-admin-password:" + k.cfg.Get("kibana_dex_pwd")))
+	hashSecret, storedHash, err := k.createStoredValue(namespace, fmt.Sprintf("dex-admin-password-%x", sum[:4]), hash)
+	if err != nil {
+		return nil, err
+	}
+	dex, err := helm.NewRelease(k.ctx, "dex", &helm.ReleaseArgs{
+		Name:      pulumi.String("dex"),
+		Namespace: namespace.Metadata.Name(),
+		Chart:     pulumi.String("dex"),
+		Version:   pulumi.String("0.13.0"),
+		RepositoryOpts: helm.RepositoryOptsArgs{
+			Repo: pulumi.String("https://charts.dexidp.io"),
+		},
+		Values: pulumi.Map{
+			"config": pulumi.Map{
+				"issuer": pulumi.String(dexIssuer),
+				"storage": pulumi.Map{
+					"type": pulumi.String("memory"),
+				},
+				"enablePasswordDB": pulumi.Bool(true),
+				"staticPasswords": pulumi.MapArray{
+					pulumi.Map{
+						"email":    pulumi.String("admin@example.com"),
+						"hash":     storedHash,
+						"username": pulumi.String("admin"),
+						"userID":   pulumi.String("08a8684b-db88-4b73-90a9-3cd1661f5466"),
+					},
+				},
+				"staticClients": pulumi.MapArray{
+					pulumi.Map{
+						"id":           pulumi.String(clientID),
+						"name":         pulumi.String("Kibana"),
+						"secret":       clientSecret,
+						"redirectURIs": pulumi.StringArray{redirectURL},
+					},
+				},
+			},
+		},
+		Timeout: pulumi.Int(300),
+	}, pulumi.Provider(k.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{hashSecret}))
+	if err != nil {
+		return nil, fmt.Errorf("creating release dex: %w", err)
+	}
+	return dex, nil
+}
+
+func ingressRule(host pulumi.StringOutput, path string, service string, port int) networkingv1.IngressRuleArgs {
+	return networkingv1.IngressRuleArgs{
+		Host: host,
+		Http: networkingv1.HTTPIngressRuleValueArgs{
+			Paths: networkingv1.HTTPIngressPathArray{
+				networkingv1.HTTPIngressPathArgs{
+					Path:     pulumi.String(path),
+					PathType: pulumi.String("Prefix"),
+					Backend: networkingv1.IngressBackendArgs{
+						Service: networkingv1.IngressServiceBackendArgs{
+							Name: pulumi.String(service),
+							Port: networkingv1.ServiceBackendPortArgs{
+								Number: pulumi.Int(port),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
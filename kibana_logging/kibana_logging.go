@@ -1,31 +1,108 @@
 package kibanalogging
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes"
 	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
 	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
 	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/meta/v1"
 	networkingv1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/networking/v1"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+	ingresscontroller "github.com/rodrigoafernandes/efk-cluster/ingress-controller"
+	"github.com/rodrigoafernandes/efk-cluster/readiness"
 )
 
+const defaultBasePath = "/kibana"
+
 type Kibana interface {
-	CreateResources(namespace *corev1.Namespace, elasticSeearch *helm.Release, hostname pulumi.StringOutput) (err error)
+	CreateResources(namespace *corev1.Namespace, elasticsearch pulumi.Resource, hostname pulumi.StringOutput) (err error)
 }
 
 type resource struct {
 	ctx      *pulumi.Context
 	provider *kubernetes.Provider
+	cfg      *config.Config
 }
 
-func NewKibana(context *pulumi.Context, provider *kubernetes.Provider) Kibana {
+func NewKibana(context *pulumi.Context, provider *kubernetes.Provider, cfg *config.Config) Kibana {
 	return resource{
 		ctx:      context,
 		provider: provider,
+		cfg:      cfg,
+	}
+}
+
+// exposure reads where Kibana is served: "kibana_host" gives it a hostname of its own,
+// "kibana_base_path" a sub-path. Without either it is served under /kibana on the
+// load balancer hostname, next to the other applications.
+func exposure(cfg *config.Config) (host, basePath string, err error) {
+	host = cfg.Get("kibana_host")
+	basePath = strings.TrimRight(cfg.Get("kibana_base_path"), "/")
+	if host == "" && basePath == "" {
+		basePath = defaultBasePath
+	}
+	if basePath != "" && !strings.HasPrefix(basePath, "/") {
+		return "", "", fmt.Errorf("kibana_base_path %q must start with /", basePath)
+	}
+	return host, basePath, nil
+}
+
+// Routes are the Ingress rules of Kibana and of the authentication in front of it.
+func Routes(cfg *config.Config) ([]ingresscontroller.Route, error) {
+	host, basePath, err := exposure(cfg)
+	if err != nil {
+		return nil, err
+	}
+	routeHost, path := ingresscontroller.LoadBalancerHost, basePath
+	if host != "" {
+		routeHost = host
+	}
+	if path == "" {
+		path = "/"
+	}
+	routes := []ingresscontroller.Route{{Ingress: "kibana-ingress", Host: routeHost, Path: path}}
+	if cfg.Get("kibana_auth") == OAuth2Auth {
+		routes = append(routes, ingresscontroller.Route{Ingress: "oauth2-proxy-ingress", Host: routeHost, Path: "/oauth2"})
 	}
+	return routes, nil
 }
 
-func (k resource) CreateResources(namespace *corev1.Namespace, elasticSearch *helm.Release, hostname pulumi.StringOutput) (err error) {
+func (k resource) CreateResources(namespace *corev1.Namespace, elasticsearch pulumi.Resource, hostname pulumi.StringOutput) (err error) {
+	host, basePath, err := exposure(k.cfg)
+	if err != nil {
+		return err
+	}
+	values := pulumi.Map{
+		"elasticsearch": pulumi.Map{
+			"hosts": pulumi.StringArray{
+				pulumi.String("elasticsearch.efk-logging.svc.cluster.local"),
+			},
+			"port": pulumi.String("9200"),
+		},
+	}
+	if k.cfg.GetBool("elasticsearch_security") {
+		// The chart sets the kibana_system password with the elastic one kept by the Elasticsearch chart.
+		values["elasticsearch"].(pulumi.Map)["security"] = pulumi.Map{
+			"auth": pulumi.Map{
+				"enabled":                     pulumi.Bool(true),
+				"kibanaPassword":              k.cfg.GetSecret("kibana_system_pwd"),
+				"createSystemUser":            pulumi.Bool(true),
+				"elasticsearchPasswordSecret": pulumi.String("elasticsearch"),
+			},
+		}
+	}
+	if basePath != "" {
+		// Kibana strips the base path itself, so the ingress forwards the path untouched.
+		values["configuration"] = pulumi.Map{
+			"server": pulumi.Map{
+				"basePath":        pulumi.String(basePath),
+				"rewriteBasePath": pulumi.Bool(true),
+			},
+		}
+	}
 	rel, err := helm.NewRelease(k.ctx, "kibana", &helm.ReleaseArgs{
 		Name:      pulumi.String("kibana"),
 		Namespace: namespace.Metadata.Name(),
@@ -34,16 +111,20 @@ func (k resource) CreateResources(namespace *corev1.Namespace, elasticSearch *he
 		RepositoryOpts: helm.RepositoryOptsArgs{
 			Repo: pulumi.String("https://charts.bitnami.com/bitnami"),
 		},
-		Values: pulumi.Map{
-			"elasticsearch": pulumi.Map{
-				"hosts": pulumi.StringArray{
-					pulumi.String("elasticsearch.efk-logging.svc.cluster.local"),
-				},
-				"port": pulumi.String("9200"),
-			},
-		},
+		Values:  values,
 		Timeout: pulumi.Int(300),
-	}, pulumi.Provider(k.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{elasticSearch}))
+	}, pulumi.Provider(k.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{elasticsearch}))
+	if err != nil {
+		return fmt.Errorf("creating release kibana: %w", err)
+	}
+	ready, err := readiness.NewJob(k.ctx, k.provider, namespace, readiness.Check{
+		Name:   "kibana-ready",
+		URL:    kibanaURL + basePath + "/api/status",
+		Expect: `"level":"available"`,
+		// The status API needs a user once elasticsearch_security is enabled.
+		User:     k.cfg.Get("elasticsearch_user"),
+		Password: k.cfg.GetSecret("elasticsearch_pwd"),
+	}, rel)
 	if err != nil {
 		return err
 	}
@@ -54,25 +135,37 @@ func (k resource) CreateResources(namespace *corev1.Namespace, elasticSearch *he
 		nil,
 		pulumi.Provider(k.provider),
 		pulumi.Parent(namespace),
-		pulumi.DependsOn([]pulumi.Resource{rel}),
+		pulumi.DependsOn([]pulumi.Resource{ready}),
 	)
+	if err != nil {
+		return fmt.Errorf("reading service kibana: %w", err)
+	}
+	ingressHost, path := hostname, basePath
+	if host != "" {
+		ingressHost = pulumi.String(host).ToStringOutput()
+	}
+	if path == "" {
+		path = "/"
+	}
+	annotations, authResources, err := k.createAuth(namespace, ingressHost)
+	if err != nil {
+		return err
+	}
+	annotations["kubernetes.io/ingress.class"] = pulumi.String("nginx")
 	_, err = networkingv1.NewIngress(k.ctx, "kibana-ingress", &networkingv1.IngressArgs{
 		Metadata: &metav1.ObjectMetaArgs{
-			Name:      pulumi.String("kibana-ingress"),
-			Namespace: namespace.Metadata.Name(),
-			Annotations: pulumi.StringMap{
-				"kubernetes.io/ingress.class":           pulumi.String("nginx"),
-				"nginx.ingress.kubernetes.io/use-regex": pulumi.String("true"),
-			},
+			Name:        pulumi.String("kibana-ingress"),
+			Namespace:   namespace.Metadata.Name(),
+			Annotations: annotations,
 		},
 		Spec: networkingv1.IngressSpecArgs{
 			Rules: networkingv1.IngressRuleArray{
 				networkingv1.IngressRuleArgs{
-					Host: hostname,
+					Host: ingressHost,
 					Http: networkingv1.HTTPIngressRuleValueArgs{
 						Paths: networkingv1.HTTPIngressPathArray{
 							networkingv1.HTTPIngressPathArgs{
-								Path:     pulumi.String("/*"),
+								Path:     pulumi.String(path),
 								PathType: pulumi.String("Prefix"),
 								Backend: networkingv1.IngressBackendArgs{
 									Service: networkingv1.IngressServiceBackendArgs{
@@ -88,7 +181,30 @@ func (k resource) CreateResources(namespace *corev1.Namespace, elasticSearch *he
 				},
 			},
 		},
-	}, pulumi.Provider(k.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{rel, svc}))
-
-	return
+	}, pulumi.Provider(k.provider), pulumi.Parent(namespace), pulumi.DependsOn(append([]pulumi.Resource{rel, svc}, authResources...)))
+	if err != nil {
+		return fmt.Errorf("creating ingress kibana-ingress: %w", err)
+	}
+	credentials, err := corev1.NewSecret(k.ctx, "kibana-api-credentials", &corev1.SecretArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("kibana-api-credentials"),
+			Namespace: namespace.Metadata.Name(),
+		},
+		Type: pulumi.String("Opaque"),
+		StringData: pulumi.StringMap{
+			"ELASTICSEARCH_USER":     pulumi.String(k.cfg.Get("elasticsearch_user")),
+			"ELASTICSEARCH_PASSWORD": k.cfg.GetSecret("elasticsearch_pwd"),
+		},
+	}, pulumi.Provider(k.provider), pulumi.Parent(namespace))
+	if err != nil {
+		return fmt.Errorf("creating secret kibana-api-credentials: %w", err)
+	}
+	savedObjects, err := k.importSavedObjects(namespace, basePath, credentials, ready)
+	if err != nil {
+		return err
+	}
+	if _, err = k.bootstrapTeams(namespace, basePath, credentials, savedObjects); err != nil {
+		return fmt.Errorf("bootstrapping teams: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,160 @@
+package kibanalogging
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	batchv1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/batch/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	logindices "github.com/rodrigoafernandes/efk-cluster/log_indices"
+)
+
+const (
+	kibanaURL       = "http://kibana.efk-logging.svc.cluster.local:5601"
+	savedObjectsDir = "kibana_logging/saved_objects"
+)
+
+// dataViewRoutes lists the Fluentd routes a data view shows when they are not just the
+// route named like it. Application logs include the logs pushed over OTLP.
+var dataViewRoutes = map[string][]string{
+	"apps-log": {"apps-log", "otlp"},
+}
+
+// dataViewTitles points the title of every data view in content at the indices its
+// routes write to, so prefix and data stream overrides of "log_indices" are followed.
+// Data views of routes that are not enabled keep the title of the file.
+func dataViewTitles(content []byte, indices []logindices.Index) ([]byte, error) {
+	lines := bytes.Split(bytes.TrimSpace(content), []byte("\n"))
+	for n, line := range lines {
+		var object map[string]interface{}
+		if err := json.Unmarshal(line, &object); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		attributes, isMap := object["attributes"].(map[string]interface{})
+		if object["type"] != "index-pattern" || !isMap {
+			continue
+		}
+		id := fmt.Sprint(object["id"])
+		routes, ok := dataViewRoutes[id]
+		if !ok {
+			routes = []string{id}
+		}
+		if title := logindices.Pattern(indices, routes...); title != "" {
+			attributes["title"] = title
+		}
+		var err error
+		if lines[n], err = json.Marshal(object); err != nil {
+			return nil, err
+		}
+	}
+	return append(bytes.Join(lines, []byte("\n")), '\n'), nil
+}
+
+// importSavedObjects loads the data views, searches, visualizations and dashboards kept
+// as NDJSON in savedObjectsDir through a Job. Every object has a fixed id and is imported
+// with overwrite, so running the Job again updates the objects instead of duplicating
+// them.
+func (k resource) importSavedObjects(namespace *corev1.Namespace, basePath string, credentials *corev1.Secret, dependsOn ...pulumi.Resource) (pulumi.Resource, error) {
+	files, err := filepath.Glob(filepath.Join(savedObjectsDir, "*.ndjson"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	indices, err := logindices.Resolve(k.cfg)
+	if err != nil {
+		return nil, err
+	}
+	objects := pulumi.StringMap{}
+	checksum := sha256.New()
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if content, err = dataViewTitles(content, indices); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		objects[filepath.Base(file)] = pulumi.String(content)
+		checksum.Write(content)
+	}
+	configMap, err := corev1.NewConfigMap(k.ctx, "kibana-saved-objects", &corev1.ConfigMapArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("kibana-saved-objects"),
+			Namespace: namespace.Metadata.Name(),
+		},
+		Data: objects,
+	}, pulumi.Provider(k.provider), pulumi.Parent(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("creating config map kibana-saved-objects: %w", err)
+	}
+	// The files are imported in a single request, so objects may reference objects of
+	// another file. The API answers 200 even when objects fail, hence the success check.
+	script := fmt.Sprintf(`auth="$ELASTICSEARCH_USER:$ELASTICSEARCH_PASSWORD"
+cat /saved-objects/*.ndjson > /tmp/saved-objects.ndjson
+curl -sf -u "$auth" -X POST -H "kbn-xsrf: true" "%s/api/saved_objects/_import?overwrite=true" \
+  --form file=@/tmp/saved-objects.ndjson | tee /tmp/response.json
+grep -q '"success":true' /tmp/response.json`, kibanaURL+basePath)
+	job, err := batchv1.NewJob(k.ctx, "kibana-saved-objects", &batchv1.JobArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Namespace: namespace.Metadata.Name(),
+		},
+		Spec: batchv1.JobSpecArgs{
+			BackoffLimit: pulumi.Int(4),
+			Template: corev1.PodTemplateSpecArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					// Changing any object changes the pod spec, which makes Pulumi run a new
+					// Job. Objects edited in Kibana are only put back when a file changes.
+					Annotations: pulumi.StringMap{
+						"checksum/saved-objects": pulumi.String(fmt.Sprintf("%x", checksum.Sum(nil))),
+					},
+				},
+				Spec: corev1.PodSpecArgs{
+					RestartPolicy: pulumi.String("OnFailure"),
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:  pulumi.String("import-saved-objects"),
+							Image: pulumi.String("docker.io/curlimages/curl:7.87.0"),
+							Command: pulumi.StringArray{
+								pulumi.String("sh"),
+								pulumi.String("-c"),
+								pulumi.String(script),
+							},
+							EnvFrom: corev1.EnvFromSourceArray{
+								corev1.EnvFromSourceArgs{
+									SecretRef: corev1.SecretEnvSourceArgs{
+										Name: credentials.Metadata.Name(),
+									},
+								},
+							},
+							VolumeMounts: corev1.VolumeMountArray{
+								corev1.VolumeMountArgs{
+									Name:      pulumi.String("saved-objects"),
+									MountPath: pulumi.String("/saved-objects"),
+								},
+							},
+						},
+					},
+					Volumes: corev1.VolumeArray{
+						corev1.VolumeArgs{
+							Name: pulumi.String("saved-objects"),
+							ConfigMap: corev1.ConfigMapVolumeSourceArgs{
+								Name: configMap.Metadata.Name(),
+							},
+						},
+					},
+				},
+			},
+		},
+	}, pulumi.Provider(k.provider), pulumi.Parent(namespace), pulumi.DependsOn(append(dependsOn, credentials, configMap)))
+	if err != nil {
+		return nil, fmt.Errorf("creating job kibana-saved-objects: %w", err)
+	}
+	return job, nil
+}
@@ -0,0 +1,238 @@
+package kibanalogging
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	batchv1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/batch/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	logindices "github.com/rodrigoafernandes/efk-cluster/log_indices"
+)
+
+const elasticsearchURL = "http://elasticsearch.efk-logging.svc.cluster.local:9200"
+
+var teamName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// team is an entry of the "kibana_teams" config list, e.g.
+// {"name": "payments", "namespaces": ["payments"], "users": ["alice"], "groups": ["payments-devs"]}.
+// Members are matched by username or group of any Elasticsearch realm.
+type team struct {
+	Name          string   `json:"name"`
+	Namespaces    []string `json:"namespaces"`
+	IndexPatterns []string `json:"index_patterns"`
+	Users         []string `json:"users"`
+	Groups        []string `json:"groups"`
+}
+
+func (t team) role() string {
+	return "team-" + t.Name
+}
+
+// configureTeams defaults the index patterns of every team to the application log
+// indices. Scoping a team by namespace relies on document level security, so it is
+// refused when "elasticsearch_license" is the basic license, which does not include it.
+func configureTeams(teams []team, license string, indices []logindices.Index) error {
+	for i, t := range teams {
+		if !teamName.MatchString(t.Name) {
+			return fmt.Errorf("kibana_teams: %q is not a valid team name, use lowercase letters, digits and dashes", t.Name)
+		}
+		if len(t.Users) == 0 && len(t.Groups) == 0 {
+			return fmt.Errorf("kibana_teams: team %s needs users or groups", t.Name)
+		}
+		if len(t.Namespaces) > 0 && (license == "" || license == "basic") {
+			return fmt.Errorf("kibana_teams: team %s is scoped by namespaces, which needs document level security: set elasticsearch_license to trial, platinum or enterprise", t.Name)
+		}
+		if len(t.IndexPatterns) == 0 {
+			teams[i].IndexPatterns = strings.Split(logindices.Pattern(indices, "apps-log", "otlp"), ",")
+		}
+	}
+	return nil
+}
+
+// teamFiles renders the space, role and role mapping payloads of every team.
+func teamFiles(teams []team) (map[string]string, error) {
+	files := map[string]string{}
+	for _, t := range teams {
+		space := map[string]interface{}{
+			"id":          t.Name,
+			"name":        t.Name,
+			"description": fmt.Sprintf("Logs of the %s team", t.Name),
+		}
+		indices := map[string]interface{}{
+			"names":      t.IndexPatterns,
+			"privileges": []string{"read", "view_index_metadata"},
+		}
+		// Every workload writes to the same indices, a team only sees the documents of its
+		// namespaces, through document level security.
+		if len(t.Namespaces) > 0 {
+			query, err := json.Marshal(map[string]interface{}{
+				"terms": map[string]interface{}{"kubernetes.namespace": t.Namespaces},
+			})
+			if err != nil {
+				return nil, err
+			}
+			indices["query"] = string(query)
+		}
+		role := map[string]interface{}{
+			"elasticsearch": map[string]interface{}{
+				"indices": []interface{}{indices},
+			},
+			"kibana": []interface{}{
+				map[string]interface{}{
+					"base":   []string{"all"},
+					"spaces": []string{t.Name},
+				},
+			},
+		}
+		var rules []interface{}
+		if len(t.Users) > 0 {
+			rules = append(rules, map[string]interface{}{"field": map[string]interface{}{"username": t.Users}})
+		}
+		if len(t.Groups) > 0 {
+			rules = append(rules, map[string]interface{}{"field": map[string]interface{}{"groups": t.Groups}})
+		}
+		roleMapping := map[string]interface{}{
+			"enabled": true,
+			"roles":   []string{t.role()},
+			"rules":   map[string]interface{}{"any": rules},
+		}
+		for suffix, payload := range map[string]interface{}{"space": space, "role": role, "role-mapping": roleMapping} {
+			content, err := json.Marshal(payload)
+			if err != nil {
+				return nil, err
+			}
+			files[t.Name+"-"+suffix+".json"] = string(content)
+		}
+	}
+	return files, nil
+}
+
+// bootstrapTeams gives every team of "kibana_teams" a Kibana space holding a copy of the
+// logging dashboards, a role that reads its logs and owns its space, and a role mapping
+// granting that role to its members. Every call updates what exists, so the Job can run
+// on every change of the teams.
+func (k resource) bootstrapTeams(namespace *corev1.Namespace, basePath string, credentials *corev1.Secret, dependsOn ...pulumi.Resource) (pulumi.Resource, error) {
+	var teams []team
+	if err := k.cfg.GetObject("kibana_teams", &teams); err != nil {
+		return nil, fmt.Errorf("kibana_teams: %w", err)
+	}
+	if len(teams) == 0 {
+		return nil, nil
+	}
+	if !k.cfg.GetBool("elasticsearch_security") {
+		return nil, fmt.Errorf("kibana_teams needs elasticsearch_security, roles do not exist without it")
+	}
+	indices, err := logindices.Resolve(k.cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err = configureTeams(teams, k.cfg.Get("elasticsearch_license"), indices); err != nil {
+		return nil, err
+	}
+	files, err := teamFiles(teams)
+	if err != nil {
+		return nil, err
+	}
+	data := pulumi.StringMap{}
+	checksum := sha256.New()
+	names := make([]string, 0, len(teams))
+	for _, t := range teams {
+		names = append(names, t.Name)
+		for _, suffix := range []string{"space", "role", "role-mapping"} {
+			file := t.Name + "-" + suffix + ".json"
+			data[file] = pulumi.String(files[file])
+			checksum.Write([]byte(files[file]))
+		}
+	}
+	configMap, err := corev1.NewConfigMap(k.ctx, "kibana-teams", &corev1.ConfigMapArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("kibana-teams"),
+			Namespace: namespace.Metadata.Name(),
+		},
+		Data: data,
+	}, pulumi.Provider(k.provider), pulumi.Parent(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("creating config map kibana-teams: %w", err)
+	}
+	// Spaces are updated with PUT and created with POST when the update finds none.
+	script := fmt.Sprintf(`set -e
+auth="$ELASTICSEARCH_USER:$ELASTICSEARCH_PASSWORD"
+for team in %[3]s; do
+  status=$(curl -s -o /dev/null -w '%%{http_code}' -u "$auth" -X PUT -H "kbn-xsrf: true" -H "Content-Type: application/json" \
+    "%[1]s/api/spaces/space/$team" -d @/teams/$team-space.json)
+  if [ "$status" = 404 ]; then
+    curl -sf -u "$auth" -X POST -H "kbn-xsrf: true" -H "Content-Type: application/json" \
+      "%[1]s/api/spaces/space" -d @/teams/$team-space.json
+  elif [ "$status" != 200 ]; then
+    echo "updating space $team returned $status"
+    exit 1
+  fi
+  curl -sf -u "$auth" -X POST -H "kbn-xsrf: true" -H "Content-Type: application/json" \
+    "%[1]s/api/spaces/_copy_saved_objects" \
+    -d "{\"spaces\":[\"$team\"],\"objects\":[{\"type\":\"dashboard\",\"id\":\"logging-overview\"}],\"includeReferences\":true,\"overwrite\":true}"
+  curl -sf -u "$auth" -X PUT -H "kbn-xsrf: true" -H "Content-Type: application/json" \
+    "%[1]s/api/security/role/team-$team" -d @/teams/$team-role.json
+  curl -sf -u "$auth" -X PUT -H "Content-Type: application/json" \
+    "%[2]s/_security/role_mapping/team-$team" -d @/teams/$team-role-mapping.json
+done`, kibanaURL+basePath, elasticsearchURL, strings.Join(names, " "))
+	job, err := batchv1.NewJob(k.ctx, "kibana-teams", &batchv1.JobArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Namespace: namespace.Metadata.Name(),
+		},
+		Spec: batchv1.JobSpecArgs{
+			BackoffLimit: pulumi.Int(4),
+			Template: corev1.PodTemplateSpecArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					// Changing any team changes the pod spec, which makes Pulumi run a new Job.
+					Annotations: pulumi.StringMap{
+						"checksum/teams": pulumi.String(fmt.Sprintf("%x", checksum.Sum(nil))),
+					},
+				},
+				Spec: corev1.PodSpecArgs{
+					RestartPolicy: pulumi.String("OnFailure"),
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:  pulumi.String("bootstrap-teams"),
+							Image: pulumi.String("docker.io/curlimages/curl:7.87.0"),
+							Command: pulumi.StringArray{
+								pulumi.String("sh"),
+								pulumi.String("-c"),
+								pulumi.String(script),
+							},
+							EnvFrom: corev1.EnvFromSourceArray{
+								corev1.EnvFromSourceArgs{
+									SecretRef: corev1.SecretEnvSourceArgs{
+										Name: credentials.Metadata.Name(),
+									},
+								},
+							},
+							VolumeMounts: corev1.VolumeMountArray{
+								corev1.VolumeMountArgs{
+									Name:      pulumi.String("teams"),
+									MountPath: pulumi.String("/teams"),
+								},
+							},
+						},
+					},
+					Volumes: corev1.VolumeArray{
+						corev1.VolumeArgs{
+							Name: pulumi.String("teams"),
+							ConfigMap: corev1.ConfigMapVolumeSourceArgs{
+								Name: configMap.Metadata.Name(),
+							},
+						},
+					},
+				},
+			},
+		},
+	}, pulumi.Provider(k.provider), pulumi.Parent(namespace), pulumi.DependsOn(append(dependsOn, credentials, configMap)))
+	if err != nil {
+		return nil, fmt.Errorf("creating job kibana-teams: %w", err)
+	}
+	return job, nil
+}
@@ -0,0 +1,212 @@
+package logalerting
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes"
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/apps/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+	logbackend "github.com/rodrigoafernandes/efk-cluster/log_backend"
+	logindices "github.com/rodrigoafernandes/efk-cluster/log_indices"
+)
+
+const (
+	rulesDir = "log_alerting/rules"
+	// receiverURL is the local webhook receiver used when "alert_webhook_url" is not set.
+	receiverURL = "http://alert-webhook-receiver.efk-logging.svc.cluster.local:8080/alerts"
+)
+
+// LogAlerting runs ElastAlert2 with the rules kept as YAML in rulesDir and posts every
+// alert to a webhook.
+type LogAlerting interface {
+	CreateResources(namespace *corev1.Namespace, backend logbackend.Endpoint, dependsOn ...pulumi.Resource) (pulumi.Resource, error)
+}
+
+type resource struct {
+	ctx      *pulumi.Context
+	provider *kubernetes.Provider
+	cfg      *config.Config
+}
+
+// NewLogAlerting returns the alerting component when "log_alerting" is enabled, nil otherwise.
+func NewLogAlerting(ctx *pulumi.Context, provider *kubernetes.Provider, cfg *config.Config) LogAlerting {
+	if !cfg.GetBool("log_alerting") {
+		return nil
+	}
+	return resource{
+		ctx:      ctx,
+		provider: provider,
+		cfg:      cfg,
+	}
+}
+
+// rule is the data the rule files are rendered with.
+type rule struct {
+	WebhookURL string
+	indices    []logindices.Index
+}
+
+// Index is the index pattern of the given Fluentd routes, following the "log_indices"
+// overrides, e.g. {{ .Index "apps-log" }}.
+func (r rule) Index(routes ...string) string {
+	return logindices.Pattern(r.indices, routes...)
+}
+
+func renderRules(data rule) (pulumi.Map, error) {
+	files, err := filepath.Glob(filepath.Join(rulesDir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	rules := pulumi.Map{}
+	for _, file := range files {
+		tmpl, err := template.ParseFiles(file)
+		if err != nil {
+			return nil, err
+		}
+		var content bytes.Buffer
+		if err = tmpl.Execute(&content, data); err != nil {
+			return nil, err
+		}
+		rules[strings.TrimSuffix(filepath.Base(file), ".yaml")] = pulumi.String(content.String())
+	}
+	return rules, nil
+}
+
+func (a resource) CreateResources(namespace *corev1.Namespace, backend logbackend.Endpoint, dependsOn ...pulumi.Resource) (pulumi.Resource, error) {
+	if backend.Kind == logbackend.Loki {
+		return nil, fmt.Errorf("log_alerting needs an Elasticsearch or OpenSearch log_backend")
+	}
+	webhookURL := a.cfg.Get("alert_webhook_url")
+	if webhookURL == "" {
+		receiver, err := a.createReceiver(namespace)
+		if err != nil {
+			return nil, err
+		}
+		webhookURL = receiverURL
+		dependsOn = append(dependsOn, receiver)
+	}
+	indices, err := logindices.Resolve(a.cfg)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := renderRules(rule{WebhookURL: webhookURL, indices: indices})
+	if err != nil {
+		return nil, err
+	}
+	useSSL := "False"
+	if backend.Scheme == "https" {
+		useSSL = "True"
+	}
+	release, err := helm.NewRelease(a.ctx, "elastalert2", &helm.ReleaseArgs{
+		Name:      pulumi.String("elastalert2"),
+		Namespace: namespace.Metadata.Name(),
+		Chart:     pulumi.String("elastalert2"),
+		Version:   pulumi.String("2.9.0"),
+		RepositoryOpts: helm.RepositoryOptsArgs{
+			Repo: pulumi.String("https://jertel.github.io/elastalert2/"),
+		},
+		Values: pulumi.Map{
+			"elasticsearch": pulumi.Map{
+				"host":     pulumi.String(backend.Host),
+				"port":     pulumi.Int(backend.Port),
+				"useSsl":   pulumi.String(useSSL),
+				"username": pulumi.String(backend.User),
+				"password": backend.Password,
+				// The OpenSearch demo certificates are self-signed.
+				"verifyCerts": pulumi.String("False"),
+			},
+			"writebackIndex": pulumi.String("elastalert"),
+			"rules":          rules,
+		},
+		Timeout: pulumi.Int(300),
+	}, pulumi.Provider(a.provider), pulumi.Parent(namespace), pulumi.DependsOn(dependsOn))
+	if err != nil {
+		return nil, fmt.Errorf("creating release elastalert2: %w", err)
+	}
+	return release, nil
+}
+
+// createReceiver deploys an HTTP server that prints every request it gets, so alerts can
+// be checked in its logs, or in the apps-log index, without an external webhook. A rule
+// is sent to it against live data with
+// kubectl -n efk-logging exec deploy/elastalert2 -- elastalert-test-rule --alert /opt/elastalert/rules/<rule>.yaml
+func (a resource) createReceiver(namespace *corev1.Namespace) (pulumi.Resource, error) {
+	receiverLabels := pulumi.StringMap{
+		"app": pulumi.String("alert-webhook-receiver"),
+	}
+	deployment, err := appsv1.NewDeployment(a.ctx, "alert-webhook-receiver", &appsv1.DeploymentArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("alert-webhook-receiver"),
+			Namespace: namespace.Metadata.Name(),
+			Labels:    receiverLabels,
+		},
+		Spec: appsv1.DeploymentSpecArgs{
+			Selector: &metav1.LabelSelectorArgs{
+				MatchLabels: receiverLabels,
+			},
+			Replicas: pulumi.Int(1),
+			Template: &corev1.PodTemplateSpecArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					Labels: receiverLabels,
+				},
+				Spec: &corev1.PodSpecArgs{
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:            pulumi.String("alert-webhook-receiver"),
+							Image:           pulumi.String("mendhak/http-https-echo:28"),
+							ImagePullPolicy: pulumi.String("IfNotPresent"),
+							Env: corev1.EnvVarArray{
+								corev1.EnvVarArgs{
+									Name:  pulumi.String("HTTP_PORT"),
+									Value: pulumi.String("8080"),
+								},
+							},
+							Ports: corev1.ContainerPortArray{
+								corev1.ContainerPortArgs{
+									ContainerPort: pulumi.Int(8080),
+								},
+							},
+							Resources: &corev1.ResourceRequirementsArgs{
+								Requests: pulumi.StringMap{
+									"memory": pulumi.String("32Mi"),
+									"cpu":    pulumi.String("10m"),
+								},
+								Limits: pulumi.StringMap{
+									"memory": pulumi.String("128Mi"),
+									"cpu":    pulumi.String("100m"),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}, pulumi.Provider(a.provider), pulumi.Parent(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("creating deployment alert-webhook-receiver: %w", err)
+	}
+	return corev1.NewService(a.ctx, "alert-webhook-receiver", &corev1.ServiceArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("alert-webhook-receiver"),
+			Namespace: namespace.Metadata.Name(),
+			Labels:    receiverLabels,
+		},
+		Spec: &corev1.ServiceSpecArgs{
+			Selector: receiverLabels,
+			Ports: corev1.ServicePortArray{
+				corev1.ServicePortArgs{
+					Port:       pulumi.Int(8080),
+					TargetPort: pulumi.Int(8080),
+				},
+			},
+		},
+	}, pulumi.Provider(a.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{deployment}))
+}
@@ -0,0 +1,108 @@
+package logalerting
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	logindices "github.com/rodrigoafernandes/efk-cluster/log_indices"
+	"gopkg.in/yaml.v3"
+)
+
+// ruleFile holds the keys of a rendered rule that decide what it reads and where its
+// alerts go.
+type ruleFile struct {
+	Name         string                   `yaml:"name"`
+	Type         string                   `yaml:"type"`
+	Index        string                   `yaml:"index"`
+	Filter       []map[string]interface{} `yaml:"filter"`
+	Alert        []string                 `yaml:"alert"`
+	URL          string                   `yaml:"http_post2_url"`
+	AllValues    bool                     `yaml:"http_post2_all_values"`
+	Payload      map[string]string        `yaml:"http_post2_payload"`
+	Fields       []string                 `yaml:"fields"`
+	QueryKey     string                   `yaml:"query_key"`
+	TimestampKey string                   `yaml:"timestamp_field"`
+}
+
+// chdirRepo runs the test from the repository root, which rulesDir is relative to.
+func chdirRepo(t *testing.T) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(".."); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(dir) })
+}
+
+// TestRenderedRulesPostToWebhook checks that every rule reads the indices its routes
+// resolve to, filters on the ECS fields Fluentd writes, and posts the matched document
+// with its name and severity to the webhook.
+func TestRenderedRulesPostToWebhook(t *testing.T) {
+	chdirRepo(t)
+	const webhookURL = "http://alert-webhook-receiver.efk-logging.svc.cluster.local:8080/alerts"
+	indices := []logindices.Index{
+		{Route: "ingress-access", Name: "ingress-access", Mode: logindices.Static},
+		{Route: "apps-log", Name: "apps", Mode: logindices.DataStream},
+	}
+	rendered, err := renderRules(rule{WebhookURL: webhookURL, indices: indices})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]ruleFile{
+		"ingress-5xx-burst": {
+			Type:   "frequency",
+			Index:  "ingress-access*",
+			Filter: []map[string]interface{}{{"range": map[string]interface{}{"http.response.status_code": map[string]interface{}{"gte": 500}}}},
+		},
+		"namespace-silent": {
+			Type:     "flatline",
+			Index:    "logs-apps-*",
+			Filter:   []map[string]interface{}{{"terms": map[string]interface{}{"kubernetes.namespace": []interface{}{"alura", "databases"}}}},
+			QueryKey: "kubernetes.namespace",
+		},
+		"new-exception-type": {
+			Type:   "new_term",
+			Index:  "logs-apps-*",
+			Filter: []map[string]interface{}{{"term": map[string]interface{}{"log.level": "error"}}},
+			Fields: []string{"error.type"},
+		},
+	}
+	severities := map[string]string{
+		"ingress-5xx-burst":  "critical",
+		"namespace-silent":   "critical",
+		"new-exception-type": "warning",
+	}
+	if len(rendered) != len(want) {
+		t.Fatalf("rendered %d rules, want %d", len(rendered), len(want))
+	}
+	for name, w := range want {
+		content, ok := rendered[name]
+		if !ok {
+			t.Errorf("rule %s is not rendered", name)
+			continue
+		}
+		var r ruleFile
+		if err := yaml.Unmarshal([]byte(fmt.Sprint(content)), &r); err != nil {
+			t.Fatalf("rule %s: %v", name, err)
+		}
+		if r.Name != name || r.Type != w.Type || r.Index != w.Index || r.TimestampKey != "@timestamp" {
+			t.Errorf("rule %s is %s %s on %s by %s, want %s %s on %s by @timestamp", name, r.Name, r.Type, r.Index, r.TimestampKey, name, w.Type, w.Index)
+		}
+		if !reflect.DeepEqual(r.Filter, w.Filter) {
+			t.Errorf("rule %s filters on %v, want %v", name, r.Filter, w.Filter)
+		}
+		if r.QueryKey != w.QueryKey || !reflect.DeepEqual(r.Fields, w.Fields) {
+			t.Errorf("rule %s keys on %q %v, want %q %v", name, r.QueryKey, r.Fields, w.QueryKey, w.Fields)
+		}
+		if !reflect.DeepEqual(r.Alert, []string{"post2"}) || r.URL != webhookURL || !r.AllValues {
+			t.Errorf("rule %s alerts with %v to %q, all values %t, want post2 of the match to the webhook", name, r.Alert, r.URL, r.AllValues)
+		}
+		if wantPayload := map[string]string{"rule": name, "severity": severities[name]}; !reflect.DeepEqual(r.Payload, wantPayload) {
+			t.Errorf("rule %s posts %v, want %v", name, r.Payload, wantPayload)
+		}
+	}
+}
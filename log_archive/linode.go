@@ -0,0 +1,71 @@
+package logarchive
+
+import (
+	"fmt"
+
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi-linode/sdk/v3/go/linode"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+type linodeArchive struct {
+	ctx *pulumi.Context
+	cfg *config.Config
+}
+
+func (l linodeArchive) CreateResources(_ *corev1.Namespace) (*Bucket, error) {
+	cluster := l.cfg.Get("log_archive_cluster")
+	if cluster == "" {
+		cluster = "us-southeast-1"
+	}
+	name := BucketName(l.cfg)
+	// Lifecycle rules are applied through the S3 API, so the bucket itself needs an unrestricted key.
+	adminKey, err := linode.NewObjectStorageKey(l.ctx, "log-archive-admin-key", &linode.ObjectStorageKeyArgs{
+		Label: pulumi.String(name + "-admin"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating object storage key log-archive-admin-key: %w", err)
+	}
+	bucket, err := linode.NewObjectStorageBucket(l.ctx, "log-archive-bucket", &linode.ObjectStorageBucketArgs{
+		Cluster:   pulumi.String(cluster),
+		Label:     pulumi.String(name),
+		Acl:       pulumi.String("private"),
+		AccessKey: adminKey.AccessKey,
+		SecretKey: adminKey.SecretKey,
+		LifecycleRules: linode.ObjectStorageBucketLifecycleRuleArray{
+			linode.ObjectStorageBucketLifecycleRuleArgs{
+				Id:      pulumi.String("retention"),
+				Enabled: pulumi.Bool(true),
+				Expiration: linode.ObjectStorageBucketLifecycleRuleExpirationArgs{
+					Days: pulumi.Int(retentionDays(l.cfg)),
+				},
+				AbortIncompleteMultipartUploadDays: pulumi.Int(1),
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating object storage bucket log-archive-bucket: %w", err)
+	}
+	writerKey, err := linode.NewObjectStorageKey(l.ctx, "log-archive-writer-key", &linode.ObjectStorageKeyArgs{
+		Label: pulumi.String(name + "-writer"),
+		BucketAccesses: linode.ObjectStorageKeyBucketAccessArray{
+			linode.ObjectStorageKeyBucketAccessArgs{
+				BucketName:  bucket.Label,
+				Cluster:     bucket.Cluster,
+				Permissions: pulumi.String("read_write"),
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating object storage key log-archive-writer-key: %w", err)
+	}
+	return &Bucket{
+		Name:      bucket.Label,
+		Endpoint:  pulumi.Sprintf("https://%s.linodeobjects.com", bucket.Cluster),
+		Region:    bucket.Cluster,
+		AccessKey: writerKey.AccessKey,
+		SecretKey: pulumi.ToSecret(writerKey.SecretKey).(pulumi.StringOutput),
+		Resource:  writerKey,
+	}, nil
+}
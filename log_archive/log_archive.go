@@ -0,0 +1,67 @@
+package logarchive
+
+import (
+	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+const (
+	defaultBucketName    = "efk-logs-archive"
+	defaultRetentionDays = 365
+)
+
+type LogArchive interface {
+	CreateResources(namespace *corev1.Namespace) (*Bucket, error)
+}
+
+// Bucket holds everything an S3-compatible client needs to write into the archive.
+type Bucket struct {
+	Name      pulumi.StringInput
+	Endpoint  pulumi.StringInput
+	Region    pulumi.StringInput
+	AccessKey pulumi.StringInput
+	SecretKey pulumi.StringInput
+	Resource  pulumi.Resource
+}
+
+// NewLogArchive returns the archive configured by "log_archive" ("linode" or "minio"),
+// or nil when archiving is disabled.
+func NewLogArchive(ctx *pulumi.Context, provider *kubernetes.Provider, cfg *config.Config) LogArchive {
+	switch cfg.Get("log_archive") {
+	case "linode":
+		return linodeArchive{
+			ctx: ctx,
+			cfg: cfg,
+		}
+	case "minio":
+		return minioArchive{
+			ctx:      ctx,
+			provider: provider,
+			cfg:      cfg,
+		}
+	}
+	return nil
+}
+
+// BucketName is the archive bucket, "log_archive_bucket" or efk-logs-archive.
+func BucketName(cfg *config.Config) string {
+	if name := cfg.Get("log_archive_bucket"); name != "" {
+		return name
+	}
+	return defaultBucketName
+}
+
+// SnapshotBucketName is the bucket the MinIO archive creates for Elasticsearch snapshots,
+// next to the archive bucket. It has no expiration rule.
+func SnapshotBucketName(cfg *config.Config) string {
+	return BucketName(cfg) + "-snapshots"
+}
+
+func retentionDays(cfg *config.Config) int {
+	if days := cfg.GetInt("log_archive_retention_days"); days > 0 {
+		return days
+	}
+	return defaultRetentionDays
+}
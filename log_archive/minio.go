@@ -0,0 +1,224 @@
+package logarchive
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes"
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/apps/v1"
+	batchv1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/batch/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+const (
+	minioImage              = "quay.io/minio/minio:RELEASE.2023-01-12T02-06-16Z"
+	minioClientImage        = "quay.io/minio/mc:RELEASE.2023-01-11T03-14-16Z"
+	defaultMinioStorageSize = "20Gi"
+)
+
+// minioArchive runs a single MinIO pod next to the logging stack, a stand-in for Object
+// Storage on local stacks. Its data is kept on a volume of "minio_storage_size", 20Gi by
+// default: MinIO stops accepting writes once it is full, so the size must hold
+// "log_archive_retention_days" of logs.
+type minioArchive struct {
+	ctx      *pulumi.Context
+	provider *kubernetes.Provider
+	cfg      *config.Config
+}
+
+func (m minioArchive) CreateResources(namespace *corev1.Namespace) (*Bucket, error) {
+	user, password := m.cfg.Require("minio_user"), m.cfg.RequireSecret("minio_pwd")
+	// MinIO refuses to start with a shorter root password.
+	if len(m.cfg.Require("minio_pwd")) < 8 {
+		return nil, fmt.Errorf("minio_pwd must be at least 8 characters long")
+	}
+	name := BucketName(m.cfg)
+	minioLabels := pulumi.StringMap{
+		"app": pulumi.String("minio"),
+	}
+	secret, err := corev1.NewSecret(m.ctx, "minio-credentials", &corev1.SecretArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("minio-credentials"),
+			Namespace: namespace.Metadata.Name(),
+		},
+		Type: pulumi.String("Opaque"),
+		StringData: pulumi.StringMap{
+			"MINIO_ROOT_USER":     pulumi.String(user),
+			"MINIO_ROOT_PASSWORD": password,
+		},
+	}, pulumi.Provider(m.provider), pulumi.Parent(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("creating secret minio-credentials: %w", err)
+	}
+	storageSize := m.cfg.Get("minio_storage_size")
+	if storageSize == "" {
+		storageSize = defaultMinioStorageSize
+	}
+	volume, err := corev1.NewPersistentVolumeClaim(m.ctx, "minio-data", &corev1.PersistentVolumeClaimArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("minio-data"),
+			Namespace: namespace.Metadata.Name(),
+		},
+		Spec: &corev1.PersistentVolumeClaimSpecArgs{
+			AccessModes:      pulumi.StringArray{pulumi.String("ReadWriteOnce")},
+			StorageClassName: pulumi.String("linode-block-storage"),
+			Resources: &corev1.ResourceRequirementsArgs{
+				Requests: pulumi.StringMap{
+					"storage": pulumi.String(storageSize),
+				},
+			},
+		},
+	}, pulumi.Provider(m.provider), pulumi.Parent(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("creating persistent volume claim minio-data: %w", err)
+	}
+	deployment, err := appsv1.NewDeployment(m.ctx, "minio", &appsv1.DeploymentArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("minio"),
+			Namespace: namespace.Metadata.Name(),
+			Labels:    minioLabels,
+		},
+		Spec: appsv1.DeploymentSpecArgs{
+			Selector: &metav1.LabelSelectorArgs{
+				MatchLabels: minioLabels,
+			},
+			Replicas: pulumi.Int(1),
+			// The volume can only be attached to one node, the old pod must release it first.
+			Strategy: &appsv1.DeploymentStrategyArgs{
+				Type: pulumi.String("Recreate"),
+			},
+			Template: &corev1.PodTemplateSpecArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					Labels: minioLabels,
+				},
+				Spec: &corev1.PodSpecArgs{
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:            pulumi.String("minio"),
+							Image:           pulumi.String(minioImage),
+							ImagePullPolicy: pulumi.String("IfNotPresent"),
+							Args: pulumi.StringArray{
+								pulumi.String("server"),
+								pulumi.String("/data"),
+							},
+							Ports: corev1.ContainerPortArray{
+								&corev1.ContainerPortArgs{
+									ContainerPort: pulumi.Int(9000),
+									Protocol:      pulumi.String("TCP"),
+								},
+							},
+							EnvFrom: &corev1.EnvFromSourceArray{
+								corev1.EnvFromSourceArgs{
+									SecretRef: corev1.SecretEnvSourceArgs{
+										Name: secret.Metadata.Name(),
+									},
+								},
+							},
+							VolumeMounts: corev1.VolumeMountArray{
+								corev1.VolumeMountArgs{
+									Name:      pulumi.String("data"),
+									MountPath: pulumi.String("/data"),
+								},
+							},
+						},
+					},
+					Volumes: corev1.VolumeArray{
+						corev1.VolumeArgs{
+							Name: pulumi.String("data"),
+							PersistentVolumeClaim: corev1.PersistentVolumeClaimVolumeSourceArgs{
+								ClaimName: volume.Metadata.Name().Elem(),
+							},
+						},
+					},
+				},
+			},
+		},
+	}, pulumi.Provider(m.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{secret, volume}))
+	if err != nil {
+		return nil, fmt.Errorf("creating deployment minio: %w", err)
+	}
+	service, err := corev1.NewService(m.ctx, "minio", &corev1.ServiceArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("minio"),
+			Namespace: namespace.Metadata.Name(),
+		},
+		Spec: &corev1.ServiceSpecArgs{
+			Selector: minioLabels,
+			Ports: &corev1.ServicePortArray{
+				corev1.ServicePortArgs{
+					Name: pulumi.String("s3"),
+					Port: pulumi.Int(9000),
+				},
+			},
+			Type: pulumi.String("ClusterIP"),
+		},
+	}, pulumi.Provider(m.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{deployment}))
+	if err != nil {
+		return nil, fmt.Errorf("creating service minio: %w", err)
+	}
+	endpoint := pulumi.Sprintf("http://%s.%s.svc.cluster.local:9000", service.Metadata.Name().Elem(), service.Metadata.Namespace().Elem())
+	job, err := m.createBuckets(namespace, secret, endpoint, service)
+	if err != nil {
+		return nil, err
+	}
+	return &Bucket{
+		Name:      pulumi.String(name),
+		Endpoint:  endpoint,
+		Region:    pulumi.String("us-east-1"),
+		AccessKey: pulumi.String(user),
+		SecretKey: password,
+		Resource:  job,
+	}, nil
+}
+
+// createBuckets creates the archive bucket with an expiration rule of
+// "log_archive_retention_days", and the Elasticsearch snapshot bucket, which has none:
+// snapshots share files, so only the SLM retention may delete them. The rules are
+// replaced on every run, so changing the retention updates them.
+func (m minioArchive) createBuckets(namespace *corev1.Namespace, credentials *corev1.Secret, endpoint pulumi.StringOutput, service pulumi.Resource) (pulumi.Resource, error) {
+	script := endpoint.ApplyT(func(endpoint string) string {
+		return fmt.Sprintf(`set -e
+until mc alias set archive %[1]s "$MINIO_ROOT_USER" "$MINIO_ROOT_PASSWORD"; do echo "waiting for minio"; sleep 5; done
+mc mb --ignore-existing archive/%[2]s archive/%[3]s
+mc ilm rm --all --force archive/%[2]s || true
+mc ilm add --expiry-days %[4]d archive/%[2]s`, endpoint, BucketName(m.cfg), SnapshotBucketName(m.cfg), retentionDays(m.cfg))
+	}).(pulumi.StringOutput)
+	job, err := batchv1.NewJob(m.ctx, "minio-buckets", &batchv1.JobArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Namespace: namespace.Metadata.Name(),
+		},
+		Spec: batchv1.JobSpecArgs{
+			BackoffLimit:          pulumi.Int(4),
+			ActiveDeadlineSeconds: pulumi.Int(600),
+			Template: corev1.PodTemplateSpecArgs{
+				Spec: corev1.PodSpecArgs{
+					RestartPolicy: pulumi.String("OnFailure"),
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:  pulumi.String("create-buckets"),
+							Image: pulumi.String(minioClientImage),
+							Command: pulumi.StringArray{
+								pulumi.String("sh"),
+								pulumi.String("-c"),
+								script,
+							},
+							EnvFrom: corev1.EnvFromSourceArray{
+								corev1.EnvFromSourceArgs{
+									SecretRef: corev1.SecretEnvSourceArgs{
+										Name: credentials.Metadata.Name(),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}, pulumi.Provider(m.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{service}))
+	if err != nil {
+		return nil, fmt.Errorf("creating job minio-buckets: %w", err)
+	}
+	return job, nil
+}
@@ -0,0 +1,48 @@
+package logbackend
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+	es "github.com/rodrigoafernandes/efk-cluster/elasticsearch_logging"
+	kibanalogging "github.com/rodrigoafernandes/efk-cluster/kibana_logging"
+)
+
+type elasticsearchBackend struct {
+	elasticsearch es.Elasticsearch
+	kibana        kibanalogging.Kibana
+	cfg           *config.Config
+}
+
+func newElasticsearchBackend(ctx *pulumi.Context, provider *kubernetes.Provider, cfg *config.Config) LogBackend {
+	return elasticsearchBackend{
+		elasticsearch: es.NewElasticsearch(ctx, provider, cfg),
+		kibana:        kibanalogging.NewKibana(ctx, provider, cfg),
+		cfg:           cfg,
+	}
+}
+
+func (b elasticsearchBackend) CreateResources(parent pulumi.Resource, hostname pulumi.StringOutput) (*corev1.Namespace, pulumi.Resource, error) {
+	namespace, ready, err := b.elasticsearch.CreateResources(parent)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = b.kibana.CreateResources(namespace, ready, hostname); err != nil {
+		return nil, nil, fmt.Errorf("kibana: %w", err)
+	}
+	return namespace, ready, nil
+}
+
+func (b elasticsearchBackend) Endpoint() Endpoint {
+	return Endpoint{
+		Kind:     Elasticsearch,
+		Scheme:   "http",
+		Host:     "elasticsearch.efk-logging.svc.cluster.local",
+		Port:     9200,
+		User:     b.cfg.Get("elasticsearch_user"),
+		Password: b.cfg.GetSecret("elasticsearch_pwd"),
+	}
+}
@@ -0,0 +1,139 @@
+package logbackend
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	presource "github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// failingMocks records every resource that is created. The resource of type failType
+// named failName is rejected while it is registered, see failOn.
+type failingMocks struct {
+	failType string
+	failName string
+	mu       sync.Mutex
+	created  []string
+}
+
+func (m *failingMocks) NewResource(args pulumi.MockResourceArgs) (string, presource.PropertyMap, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.created = append(m.created, args.TypeToken+" "+args.Name)
+	return args.Name + "-id", args.Inputs, nil
+}
+
+func (m *failingMocks) Call(args pulumi.MockCallArgs) (presource.PropertyMap, error) {
+	return args.Args, nil
+}
+
+// failOn moves the failing resource under another parent, which the SDK refuses when it
+// registers the resource. The error is returned by the constructor, so it goes through
+// the component's own error handling as any invalid resource would.
+func (m *failingMocks) failOn(ctx *pulumi.Context) error {
+	if m.failType == "" {
+		return nil
+	}
+	parent, err := corev1.NewNamespace(ctx, "failure-parent", &corev1.NamespaceArgs{})
+	if err != nil {
+		return err
+	}
+	return ctx.RegisterStackTransformation(func(args *pulumi.ResourceTransformationArgs) *pulumi.ResourceTransformationResult {
+		if args.Type != m.failType || args.Name != m.failName {
+			return nil
+		}
+		return &pulumi.ResourceTransformationResult{Props: args.Props, Opts: append(args.Opts, pulumi.Parent(parent))}
+	})
+}
+
+// chdirRepo runs the test from the repository root, which the node types and the saved
+// objects are read from.
+func chdirRepo(t *testing.T) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(".."); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(dir) })
+}
+
+// runBackend deploys Elasticsearch and Kibana alone against mocks, as main.go does.
+func runBackend(mocks *failingMocks) error {
+	return pulumi.RunErr(func(ctx *pulumi.Context) error {
+		if err := mocks.failOn(ctx); err != nil {
+			return err
+		}
+		provider, err := kubernetes.NewProvider(ctx, "k8s", &kubernetes.ProviderArgs{})
+		if err != nil {
+			return err
+		}
+		backend, err := NewLogBackend(ctx, provider, config.New(ctx, ""))
+		if err != nil {
+			return err
+		}
+		if _, _, err = backend.CreateResources(provider, pulumi.String("lb.example.com").ToStringOutput()); err != nil {
+			return fmt.Errorf("log backend: %w", err)
+		}
+		return nil
+	}, pulumi.WithMocks("efk-cluster", "test", mocks))
+}
+
+func TestElasticsearchBackendDeploys(t *testing.T) {
+	chdirRepo(t)
+	mocks := &failingMocks{}
+	if err := runBackend(mocks); err != nil {
+		t.Fatal(err)
+	}
+	for _, created := range mocks.created {
+		if created == "kubernetes:batch/v1:Job kibana-saved-objects" {
+			return
+		}
+	}
+	t.Errorf("the saved objects were not imported: %v", mocks.created)
+}
+
+// TestFailedResourceStopsDeployment fails one resource of Elasticsearch or Kibana at a
+// time and checks that the program returns the error of the component naming it and that
+// nothing depending on it is created.
+func TestFailedResourceStopsDeployment(t *testing.T) {
+	chdirRepo(t)
+	cases := []struct {
+		failType   string
+		failName   string
+		prefix     string
+		notCreated []string
+	}{
+		{"kubernetes:core/v1:Namespace", "efk-namespace", "log backend: creating namespace efk-namespace: ", []string{"kubernetes:helm.sh/v3:Release elasticsearch", "kubernetes:helm.sh/v3:Release kibana"}},
+		{"kubernetes:helm.sh/v3:Release", "elasticsearch", "log backend: creating release elasticsearch: ", []string{"kubernetes:policy/v1:PodDisruptionBudget elasticsearch-master-pdb", "kubernetes:batch/v1:Job elasticsearch-ready", "kubernetes:helm.sh/v3:Release kibana"}},
+		{"kubernetes:policy/v1:PodDisruptionBudget", "elasticsearch-data-pdb", "log backend: creating pod disruption budget elasticsearch-data-pdb: ", []string{"kubernetes:batch/v1:Job elasticsearch-ready", "kubernetes:helm.sh/v3:Release kibana"}},
+		{"kubernetes:batch/v1:Job", "elasticsearch-index-templates", "log backend: creating job elasticsearch-index-templates: ", []string{"kubernetes:helm.sh/v3:Release kibana"}},
+		{"kubernetes:helm.sh/v3:Release", "kibana", "log backend: kibana: creating release kibana: ", []string{"kubernetes:batch/v1:Job kibana-ready", "kubernetes:networking.k8s.io/v1:Ingress kibana-ingress"}},
+		{"kubernetes:networking.k8s.io/v1:Ingress", "kibana-ingress", "log backend: kibana: creating ingress kibana-ingress: ", []string{"kubernetes:core/v1:Secret kibana-api-credentials", "kubernetes:batch/v1:Job kibana-saved-objects"}},
+		{"kubernetes:core/v1:ConfigMap", "kibana-saved-objects", "log backend: kibana: creating config map kibana-saved-objects: ", []string{"kubernetes:batch/v1:Job kibana-saved-objects"}},
+	}
+	for _, c := range cases {
+		t.Run(c.failName, func(t *testing.T) {
+			mocks := &failingMocks{failType: c.failType, failName: c.failName}
+			err := runBackend(mocks)
+			if err == nil || !strings.Contains(err.Error(), c.prefix) {
+				t.Fatalf("error is %v, want %q", err, c.prefix)
+			}
+			for _, created := range mocks.created {
+				for _, notCreated := range c.notCreated {
+					if created == notCreated {
+						t.Errorf("%s was created after %s failed", created, c.failName)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package logbackend
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+	ingresscontroller "github.com/rodrigoafernandes/efk-cluster/ingress-controller"
+	kibanalogging "github.com/rodrigoafernandes/efk-cluster/kibana_logging"
+	lokilogging "github.com/rodrigoafernandes/efk-cluster/loki_logging"
+	opensearchlogging "github.com/rodrigoafernandes/efk-cluster/opensearch_logging"
+)
+
+type Kind string
+
+const (
+	Elasticsearch Kind = "elasticsearch"
+	OpenSearch    Kind = "opensearch"
+	Loki          Kind = "loki"
+)
+
+// LogBackend stores the collected logs and serves the UI used to browse them.
+// CreateResources returns the resource to depend on, which is only created once the
+// store accepts logs.
+type LogBackend interface {
+	CreateResources(parent pulumi.Resource, hostname pulumi.StringOutput) (*corev1.Namespace, pulumi.Resource, error)
+	Endpoint() Endpoint
+}
+
+// Endpoint tells Fluentd which output plugin to use and where the backend listens.
+type Endpoint struct {
+	Kind     Kind
+	Scheme   string
+	Host     string
+	Port     int
+	User     string
+	Password pulumi.StringOutput
+}
+
+// NewLogBackend returns the backend selected by "log_backend", defaulting to Elasticsearch.
+func NewLogBackend(ctx *pulumi.Context, provider *kubernetes.Provider, cfg *config.Config) (LogBackend, error) {
+	kind := Kind(cfg.Get("log_backend"))
+	switch kind {
+	case "", Elasticsearch:
+		return newElasticsearchBackend(ctx, provider, cfg), nil
+	case OpenSearch:
+		return newOpenSearchBackend(ctx, provider, cfg), nil
+	case Loki:
+		return newLokiBackend(ctx, provider, cfg), nil
+	}
+	return nil, fmt.Errorf("unsupported log_backend %q", kind)
+}
+
+// Routes are the Ingress rules of the backend selected by "log_backend".
+func Routes(cfg *config.Config) ([]ingresscontroller.Route, error) {
+	kind := Kind(cfg.Get("log_backend"))
+	switch kind {
+	case "", Elasticsearch:
+		return kibanalogging.Routes(cfg)
+	case OpenSearch:
+		return opensearchlogging.Routes(), nil
+	case Loki:
+		return lokilogging.Routes(), nil
+	}
+	return nil, fmt.Errorf("unsupported log_backend %q", kind)
+}
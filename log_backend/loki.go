@@ -0,0 +1,34 @@
+package logbackend
+
+import (
+	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+	lokilogging "github.com/rodrigoafernandes/efk-cluster/loki_logging"
+)
+
+type lokiBackend struct {
+	loki lokilogging.Loki
+}
+
+func newLokiBackend(ctx *pulumi.Context, provider *kubernetes.Provider, cfg *config.Config) LogBackend {
+	return lokiBackend{
+		loki: lokilogging.NewLoki(ctx, provider, cfg),
+	}
+}
+
+func (b lokiBackend) CreateResources(parent pulumi.Resource, hostname pulumi.StringOutput) (*corev1.Namespace, pulumi.Resource, error) {
+	return b.loki.CreateResources(parent, hostname)
+}
+
+// Endpoint has no credentials because Loki runs with auth disabled inside the cluster.
+func (b lokiBackend) Endpoint() Endpoint {
+	return Endpoint{
+		Kind:     Loki,
+		Scheme:   "http",
+		Host:     "loki.efk-logging.svc.cluster.local",
+		Port:     3100,
+		Password: pulumi.String("").ToStringOutput(),
+	}
+}
@@ -0,0 +1,36 @@
+package logbackend
+
+import (
+	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+	opensearchlogging "github.com/rodrigoafernandes/efk-cluster/opensearch_logging"
+)
+
+type openSearchBackend struct {
+	openSearch opensearchlogging.OpenSearch
+	cfg        *config.Config
+}
+
+func newOpenSearchBackend(ctx *pulumi.Context, provider *kubernetes.Provider, cfg *config.Config) LogBackend {
+	return openSearchBackend{
+		openSearch: opensearchlogging.NewOpenSearch(ctx, provider, cfg),
+		cfg:        cfg,
+	}
+}
+
+func (b openSearchBackend) CreateResources(parent pulumi.Resource, hostname pulumi.StringOutput) (*corev1.Namespace, pulumi.Resource, error) {
+	return b.openSearch.CreateResources(parent, hostname)
+}
+
+func (b openSearchBackend) Endpoint() Endpoint {
+	return Endpoint{
+		Kind:     OpenSearch,
+		Scheme:   "https",
+		Host:     "opensearch-cluster-master.efk-logging.svc.cluster.local",
+		Port:     9200,
+		User:     b.cfg.Get("opensearch_user"),
+		Password: b.cfg.GetSecret("opensearch_pwd"),
+	}
+}
@@ -0,0 +1,141 @@
+package logindices
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// Mode decides how a route names the indices it writes to.
+type Mode string
+
+const (
+	// Static writes everything into a single index named after the route.
+	Static Mode = "static"
+	// Daily writes into <index>-YYYY.MM.DD, so retention is enforced by deleting old indices.
+	Daily Mode = "daily"
+	// DataStream writes into the logs-<index>-default data stream, rolled over by ILM.
+	DataStream Mode = "datastream"
+)
+
+// Index is where the events of one Fluentd route are written.
+type Index struct {
+	Route string
+	Name  string
+	Mode  Mode
+}
+
+// naming is the per route override read from the "log_indices" config object, e.g.
+// {"apps-log": {"mode": "daily"}, "k8s-events": {"mode": "datastream", "prefix": "events"}}.
+type naming struct {
+	Mode   Mode   `json:"mode"`
+	Prefix string `json:"prefix"`
+}
+
+// DataStreamName follows the logs-<dataset>-<namespace> scheme matched by the built-in
+// Elasticsearch logs template, which enables data streams for it.
+func (i Index) DataStreamName() string {
+	return "logs-" + i.Name + "-default"
+}
+
+// Pattern matches every index or data stream the route writes to.
+func (i Index) Pattern() string {
+	if i.Mode == DataStream {
+		return "logs-" + i.Name + "-*"
+	}
+	return i.Name + "*"
+}
+
+// Resolve returns the index of every route cfg enables, in the order Fluentd renders
+// them: log-throttle with "log_throttle", ingress-access, k8s-events, apps-log, and otlp
+// with "otlp_ingest". The "log_indices" overrides are applied, a prefix replaces the
+// index name.
+func Resolve(cfg *config.Config) ([]Index, error) {
+	var defaults []Index
+	if cfg.Get("log_throttle") != "" {
+		defaults = append(defaults, Index{Route: "log-throttle", Name: "log-throttle"})
+	}
+	defaults = append(defaults,
+		Index{Route: "ingress-access", Name: "ingress-access"},
+		Index{Route: "k8s-events", Name: "k8s-events"},
+		Index{Route: "apps-log", Name: "apps-log"},
+	)
+	// The collector logs are application logs too, and share their index by default.
+	if cfg.GetBool("otlp_ingest") {
+		defaults = append(defaults, Index{Route: "otlp", Name: "apps-log"})
+	}
+	var overrides map[string]naming
+	if err := cfg.GetObject("log_indices", &overrides); err != nil {
+		return nil, fmt.Errorf("log_indices: %w", err)
+	}
+	indices := make([]Index, 0, len(defaults))
+	for _, i := range defaults {
+		override, ok := overrides[i.Route]
+		delete(overrides, i.Route)
+		if ok {
+			if override.Prefix != "" {
+				i.Name = override.Prefix
+			}
+			i.Mode = override.Mode
+		}
+		switch i.Mode {
+		case "":
+			i.Mode = Static
+		case Static, Daily, DataStream:
+		default:
+			return nil, fmt.Errorf("log_indices: route %s has unsupported mode %q", i.Route, i.Mode)
+		}
+		indices = append(indices, i)
+	}
+	for route := range overrides {
+		return nil, fmt.Errorf("log_indices: unknown route %s", route)
+	}
+	return indices, nil
+}
+
+// Find returns the index of route, false when cfg does not enable it.
+func Find(indices []Index, route string) (Index, bool) {
+	for _, i := range indices {
+		if i.Route == route {
+			return i, true
+		}
+	}
+	return Index{}, false
+}
+
+// Pattern joins the distinct patterns of the given routes with commas, the multi-target
+// syntax of searches and data views. Routes that are not enabled are skipped.
+func Pattern(indices []Index, routes ...string) string {
+	var patterns []string
+	for _, route := range routes {
+		i, ok := Find(indices, route)
+		if !ok || contains(patterns, i.Pattern()) {
+			continue
+		}
+		patterns = append(patterns, i.Pattern())
+	}
+	return strings.Join(patterns, ",")
+}
+
+// Patterns lists the distinct patterns of the indices written as data streams when
+// dataStreams is true, or of the plain indices otherwise.
+func Patterns(indices []Index, dataStreams bool) []string {
+	var patterns []string
+	for _, i := range indices {
+		if (i.Mode == DataStream) != dataStreams || contains(patterns, i.Pattern()) {
+			continue
+		}
+		patterns = append(patterns, i.Pattern())
+	}
+	return patterns
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
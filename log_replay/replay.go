@@ -0,0 +1,211 @@
+package logreplay
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const maxAttempts = 5
+
+// backoff is the pause before retrying a bulk request that failed attempt times.
+var backoff = func(attempt int) time.Duration {
+	return time.Duration(attempt*attempt) * time.Second
+}
+
+// Options configures where and how fast archived chunks are replayed.
+type Options struct {
+	URL       string
+	User      string
+	Password  string
+	Index     string
+	BatchSize int
+	// Rate caps the number of documents sent per second, zero means unlimited.
+	Rate   float64
+	Client *http.Client
+}
+
+// Stats counts what happened to the replayed lines. Duplicates are documents that were
+// already indexed by a previous replay, which is what makes replaying a chunk twice safe.
+type Stats struct {
+	Read       int
+	Created    int
+	Duplicates int
+	Failed     int
+}
+
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+// Replay bulk-indexes every JSON line of files, which may be gzip compressed, into
+// opts.Index. Each document id is derived from the line content, and documents are sent
+// with the create action, so lines already present are reported as duplicates instead
+// of being indexed again.
+func Replay(ctx context.Context, opts Options, files []string) (Stats, error) {
+	var stats Stats
+	if opts.Index == "" {
+		return stats, fmt.Errorf("an index is required")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	batch := make([][]byte, 0, opts.BatchSize)
+	var lastFlush time.Time
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		// Space the batches so the average throughput stays under the configured rate.
+		if opts.Rate > 0 && !lastFlush.IsZero() {
+			wait := time.Duration(float64(len(batch))/opts.Rate*float64(time.Second)) - time.Since(lastFlush)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+		lastFlush = time.Now()
+		err := sendBatch(ctx, opts, batch, &stats)
+		batch = batch[:0]
+		return err
+	}
+	for _, file := range files {
+		err := readLines(file, func(line []byte) error {
+			stats.Read++
+			batch = append(batch, line)
+			if len(batch) == opts.BatchSize {
+				return flush()
+			}
+			return nil
+		})
+		if err != nil {
+			return stats, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return stats, flush()
+}
+
+func readLines(file string, fn func(line []byte) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var reader io.Reader = f
+	if strings.HasSuffix(file, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			return fmt.Errorf("line is not a JSON document: %.80s", line)
+		}
+		if err := fn(append([]byte(nil), line...)); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// documentID hashes the raw line, so the same archived event always maps to the same id.
+func documentID(line []byte) string {
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:20])
+}
+
+func sendBatch(ctx context.Context, opts Options, batch [][]byte, stats *Stats) error {
+	var body bytes.Buffer
+	for _, line := range batch {
+		fmt.Fprintf(&body, `{"create":{"_index":%q,"_id":%q}}`+"\n", opts.Index, documentID(line))
+		body.Write(line)
+		body.WriteByte('\n')
+	}
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		response, retry, err := postBulk(ctx, opts, body.Bytes())
+		if err == nil {
+			countItems(response, stats)
+			return nil
+		}
+		if !retry {
+			return err
+		}
+		lastErr = err
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff(attempt)):
+		}
+	}
+	return fmt.Errorf("bulk request failed after %d attempts: %w", maxAttempts, lastErr)
+}
+
+// postBulk sends one _bulk request. retry is true for errors worth another attempt:
+// transport failures, 429 Too Many Requests and 5xx answers.
+func postBulk(ctx context.Context, opts Options, body []byte) (response bulkResponse, retry bool, err error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(opts.URL, "/")+"/_bulk", bytes.NewReader(body))
+	if err != nil {
+		return response, false, err
+	}
+	request.Header.Set("Content-Type", "application/x-ndjson")
+	if opts.User != "" {
+		request.SetBasicAuth(opts.User, opts.Password)
+	}
+	resp, err := opts.Client.Do(request)
+	if err != nil {
+		return response, true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return response, true, fmt.Errorf("bulk request returned %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return response, false, fmt.Errorf("bulk request returned %s: %s", resp.Status, message)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	return response, false, err
+}
+
+func countItems(response bulkResponse, stats *Stats) {
+	for _, item := range response.Items {
+		for _, result := range item {
+			switch {
+			case result.Status == http.StatusConflict:
+				stats.Duplicates++
+			case result.Status >= 200 && result.Status < 300:
+				stats.Created++
+			default:
+				stats.Failed++
+			}
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package logreplay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBulk is a _bulk endpoint that keeps the created ids, answers 409 for the ones it
+// already has, and fails the first requests with the statuses in failures.
+type fakeBulk struct {
+	mu       sync.Mutex
+	ids      map[string]bool
+	failures []int
+	requests []time.Time
+}
+
+func (f *fakeBulk) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, time.Now())
+	if len(f.failures) > 0 {
+		status := f.failures[0]
+		f.failures = f.failures[1:]
+		w.WriteHeader(status)
+		return
+	}
+	var items []map[string]map[string]int
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var action map[string]struct {
+			ID string `json:"_id"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &action); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		scanner.Scan() // the document
+		status := http.StatusCreated
+		if f.ids[action["create"].ID] {
+			status = http.StatusConflict
+		}
+		f.ids[action["create"].ID] = true
+		items = append(items, map[string]map[string]int{"create": {"status": status}})
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"errors": false, "items": items})
+}
+
+func newFakeBulk(t *testing.T, failures ...int) (*fakeBulk, string) {
+	fake := &fakeBulk{ids: map[string]bool{}, failures: failures}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server.URL
+}
+
+func writeChunk(t *testing.T, lines int) string {
+	var content strings.Builder
+	for i := 0; i < lines; i++ {
+		fmt.Fprintf(&content, `{"@timestamp":"2023-01-20T10:00:00.%03dZ","message":"line %d"}`+"\n", i, i)
+	}
+	file := filepath.Join(t.TempDir(), "chunk.log")
+	if err := os.WriteFile(file, []byte(content.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func noBackoff(t *testing.T) *[]time.Duration {
+	var waits []time.Duration
+	previous := backoff
+	backoff = func(attempt int) time.Duration {
+		waits = append(waits, previous(attempt))
+		return time.Millisecond
+	}
+	t.Cleanup(func() { backoff = previous })
+	return &waits
+}
+
+func TestReplayTwiceCreatesNoDuplicates(t *testing.T) {
+	fake, url := newFakeBulk(t)
+	file := writeChunk(t, 25)
+	opts := Options{URL: url, Index: "apps-log-replay", BatchSize: 10}
+
+	first, err := Replay(context.Background(), opts, []string{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != (Stats{Read: 25, Created: 25}) {
+		t.Errorf("first replay: %+v", first)
+	}
+	second, err := Replay(context.Background(), opts, []string{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second != (Stats{Read: 25, Duplicates: 25}) {
+		t.Errorf("second replay: %+v", second)
+	}
+	if len(fake.ids) != 25 {
+		t.Errorf("index holds %d documents, want 25", len(fake.ids))
+	}
+}
+
+func TestReplayRetriesThrottledAndFailedRequests(t *testing.T) {
+	waits := noBackoff(t)
+	fake, url := newFakeBulk(t, http.StatusTooManyRequests, http.StatusServiceUnavailable)
+	file := writeChunk(t, 5)
+
+	stats, err := Replay(context.Background(), Options{URL: url, Index: "apps-log-replay"}, []string{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (Stats{Read: 5, Created: 5}) {
+		t.Errorf("stats: %+v", stats)
+	}
+	if len(fake.requests) != 3 {
+		t.Errorf("sent %d requests, want 3", len(fake.requests))
+	}
+	if want := []time.Duration{time.Second, 4 * time.Second}; fmt.Sprint(*waits) != fmt.Sprint(want) {
+		t.Errorf("backed off %v, want %v", *waits, want)
+	}
+}
+
+func TestReplayGivesUpAfterMaxAttempts(t *testing.T) {
+	noBackoff(t)
+	failures := make([]int, maxAttempts)
+	for i := range failures {
+		failures[i] = http.StatusBadGateway
+	}
+	fake, url := newFakeBulk(t, failures...)
+
+	_, err := Replay(context.Background(), Options{URL: url, Index: "apps-log-replay"}, []string{writeChunk(t, 1)})
+	if err == nil || !strings.Contains(err.Error(), "after 5 attempts") {
+		t.Errorf("error is %v, want the bulk request to fail after 5 attempts", err)
+	}
+	if len(fake.requests) != maxAttempts {
+		t.Errorf("sent %d requests, want %d", len(fake.requests), maxAttempts)
+	}
+}
+
+func TestReplayDoesNotRetryClientErrors(t *testing.T) {
+	fake, url := newFakeBulk(t, http.StatusUnauthorized)
+
+	_, err := Replay(context.Background(), Options{URL: url, Index: "apps-log-replay"}, []string{writeChunk(t, 1)})
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("error is %v, want the 401 answer", err)
+	}
+	if len(fake.requests) != 1 {
+		t.Errorf("sent %d requests, want 1", len(fake.requests))
+	}
+}
+
+func TestReplayRate(t *testing.T) {
+	fake, url := newFakeBulk(t)
+	file := writeChunk(t, 40)
+
+	// 10 documents per batch at 200 documents per second is a batch every 50ms.
+	_, err := Replay(context.Background(), Options{URL: url, Index: "apps-log-replay", BatchSize: 10, Rate: 200}, []string{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.requests) != 4 {
+		t.Fatalf("sent %d requests, want 4", len(fake.requests))
+	}
+	for i := 1; i < len(fake.requests); i++ {
+		if gap := fake.requests[i].Sub(fake.requests[i-1]); gap < 45*time.Millisecond {
+			t.Errorf("batch %d sent %v after the previous one, want at least 50ms", i+1, gap)
+		}
+	}
+}
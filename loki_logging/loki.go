@@ -0,0 +1,186 @@
+package lokilogging
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/meta/v1"
+	networkingv1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/networking/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+	ingresscontroller "github.com/rodrigoafernandes/efk-cluster/ingress-controller"
+	"github.com/rodrigoafernandes/efk-cluster/readiness"
+)
+
+type Loki interface {
+	CreateResources(parent pulumi.Resource, hostname pulumi.StringOutput) (*corev1.Namespace, pulumi.Resource, error)
+}
+
+type resource struct {
+	ctx      *pulumi.Context
+	provider *kubernetes.Provider
+	cfg      *config.Config
+}
+
+func NewLoki(ctx *pulumi.Context, provider *kubernetes.Provider, cfg *config.Config) Loki {
+	return resource{
+		ctx:      ctx,
+		provider: provider,
+		cfg:      cfg,
+	}
+}
+
+// Routes are the Ingress rules of Grafana.
+func Routes() []ingresscontroller.Route {
+	return []ingresscontroller.Route{{Ingress: "grafana-ingress", Host: ingresscontroller.LoadBalancerHost, Path: "/grafana"}}
+}
+
+func (l resource) CreateResources(parent pulumi.Resource, hostname pulumi.StringOutput) (*corev1.Namespace, pulumi.Resource, error) {
+	namespace, err := corev1.NewNamespace(l.ctx, "efk-namespace", &corev1.NamespaceArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Labels: pulumi.StringMap{
+				"name": pulumi.String("efk-logging"),
+			},
+			Name: pulumi.String("efk-logging"),
+		},
+	}, pulumi.Provider(l.provider), pulumi.DependsOn([]pulumi.Resource{parent}))
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating namespace efk-namespace: %w", err)
+	}
+	// Single binary mode keeps every Loki component in one pod backed by the filesystem.
+	release, err := helm.NewRelease(l.ctx, "loki", &helm.ReleaseArgs{
+		Name:      pulumi.String("loki"),
+		Namespace: namespace.Metadata.Name(),
+		Chart:     pulumi.String("loki"),
+		Version:   pulumi.String("4.4.2"),
+		RepositoryOpts: helm.RepositoryOptsArgs{
+			Repo: pulumi.String("https://grafana.github.io/helm-charts"),
+		},
+		Values: pulumi.Map{
+			"loki": pulumi.Map{
+				"auth_enabled": pulumi.Bool(false),
+				"commonConfig": pulumi.Map{
+					"replication_factor": pulumi.Int(1),
+				},
+				"storage": pulumi.Map{
+					"type": pulumi.String("filesystem"),
+				},
+			},
+			"singleBinary": pulumi.Map{
+				"replicas": pulumi.Int(1),
+				"persistence": pulumi.Map{
+					"storageClass": pulumi.String("linode-block-storage"),
+				},
+			},
+			"monitoring": pulumi.Map{
+				"selfMonitoring": pulumi.Map{
+					"enabled": pulumi.Bool(false),
+					"grafanaAgent": pulumi.Map{
+						"installOperator": pulumi.Bool(false),
+					},
+				},
+				"lokiCanary": pulumi.Map{
+					"enabled": pulumi.Bool(false),
+				},
+			},
+			"test": pulumi.Map{
+				"enabled": pulumi.Bool(false),
+			},
+		},
+		Timeout: pulumi.Int(300),
+	}, pulumi.Provider(l.provider), pulumi.Parent(namespace))
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating release loki: %w", err)
+	}
+	ready, err := readiness.NewJob(l.ctx, l.provider, namespace, readiness.Check{
+		Name:   "loki-ready",
+		URL:    "http://loki.efk-logging.svc.cluster.local:3100/ready",
+		Expect: "ready",
+	}, release)
+	if err != nil {
+		return nil, nil, err
+	}
+	grafana, err := helm.NewRelease(l.ctx, "grafana", &helm.ReleaseArgs{
+		Name:      pulumi.String("grafana"),
+		Namespace: namespace.Metadata.Name(),
+		Chart:     pulumi.String("grafana"),
+		Version:   pulumi.String("6.50.7"),
+		RepositoryOpts: helm.RepositoryOptsArgs{
+			Repo: pulumi.String("https://grafana.github.io/helm-charts"),
+		},
+		Values: pulumi.Map{
+			"adminPassword": l.cfg.GetSecret("grafana_pwd"),
+			// Served under /grafana, next to the other applications of the load balancer hostname.
+			"grafana.ini": pulumi.Map{
+				"server": pulumi.Map{
+					"root_url":            pulumi.String("%(protocol)s://%(domain)s/grafana"),
+					"serve_from_sub_path": pulumi.Bool(true),
+				},
+			},
+			"datasources": pulumi.Map{
+				"datasources.yaml": pulumi.Map{
+					"apiVersion": pulumi.Int(1),
+					"datasources": pulumi.MapArray{
+						pulumi.Map{
+							"name":      pulumi.String("Loki"),
+							"type":      pulumi.String("loki"),
+							"access":    pulumi.String("proxy"),
+							"url":       pulumi.String("http://loki.efk-logging.svc.cluster.local:3100"),
+							"isDefault": pulumi.Bool(true),
+						},
+					},
+				},
+			},
+		},
+		Timeout: pulumi.Int(300),
+	}, pulumi.Provider(l.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{ready}))
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating release grafana: %w", err)
+	}
+	grafanaReady, err := readiness.NewJob(l.ctx, l.provider, namespace, readiness.Check{
+		Name:   "grafana-ready",
+		URL:    "http://grafana.efk-logging.svc.cluster.local/grafana/api/health",
+		Expect: `"database": "ok"`,
+	}, grafana)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = networkingv1.NewIngress(l.ctx, "grafana-ingress", &networkingv1.IngressArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("grafana-ingress"),
+			Namespace: namespace.Metadata.Name(),
+			Annotations: pulumi.StringMap{
+				"kubernetes.io/ingress.class": pulumi.String("nginx"),
+			},
+		},
+		Spec: networkingv1.IngressSpecArgs{
+			Rules: networkingv1.IngressRuleArray{
+				networkingv1.IngressRuleArgs{
+					Host: hostname,
+					Http: networkingv1.HTTPIngressRuleValueArgs{
+						Paths: networkingv1.HTTPIngressPathArray{
+							networkingv1.HTTPIngressPathArgs{
+								Path:     pulumi.String("/grafana"),
+								PathType: pulumi.String("Prefix"),
+								Backend: networkingv1.IngressBackendArgs{
+									Service: networkingv1.IngressServiceBackendArgs{
+										Name: pulumi.String("grafana"),
+										Port: networkingv1.ServiceBackendPortArgs{
+											Number: pulumi.Int(80),
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}, pulumi.Provider(l.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{grafanaReady}))
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating ingress grafana-ingress: %w", err)
+	}
+	return namespace, ready, nil
+}
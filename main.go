@@ -1,67 +1,122 @@
 package main
 
 import (
+	"fmt"
+
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
 	"github.com/rodrigoafernandes/efk-cluster/app"
 	"github.com/rodrigoafernandes/efk-cluster/cluster"
-	es "github.com/rodrigoafernandes/efk-cluster/elasticsearch_logging"
+	eventsexporter "github.com/rodrigoafernandes/efk-cluster/events_exporter"
 	fluentdlogging "github.com/rodrigoafernandes/efk-cluster/fluentd_logging"
 	ingresscontroller "github.com/rodrigoafernandes/efk-cluster/ingress-controller"
-	kibanalogging "github.com/rodrigoafernandes/efk-cluster/kibana_logging"
+	kafkabuffer "github.com/rodrigoafernandes/efk-cluster/kafka_buffer"
+	logalerting "github.com/rodrigoafernandes/efk-cluster/log_alerting"
+	logarchive "github.com/rodrigoafernandes/efk-cluster/log_archive"
+	logbackend "github.com/rodrigoafernandes/efk-cluster/log_backend"
 	metricsserver "github.com/rodrigoafernandes/efk-cluster/metrics-server"
 	"github.com/rodrigoafernandes/efk-cluster/mongodb"
+	otlpingest "github.com/rodrigoafernandes/efk-cluster/otlp_ingest"
 	"github.com/rodrigoafernandes/efk-cluster/redis"
 )
 
 func main() {
 	pulumi.Run(func(ctx *pulumi.Context) error {
 		cfg := config.New(ctx, "")
-		k8sCluster := cluster.NewCluster(ctx)
-		provider, err := k8sCluster.Create()
+		routes, err := declareRoutes(cfg)
 		if err != nil {
 			return err
 		}
+		if err = routes.Validate(); err != nil {
+			return err
+		}
+		k8sCluster := cluster.NewCluster(ctx, cfg)
+		provider, err := k8sCluster.Create()
+		if err != nil {
+			return fmt.Errorf("cluster: %w", err)
+		}
 		metricsServer := metricsserver.NewMetricsServerResource(ctx, provider)
 		metricsServerResource, err := metricsServer.CreateResources()
 		if err != nil {
-			return err
+			return fmt.Errorf("metrics server: %w", err)
 		}
 		ingressNginx := ingresscontroller.NewNginxIngressController(ctx, provider)
 		ingressController, hostname, err := ingressNginx.CreateResources(metricsServerResource)
 		if err != nil {
-			return err
+			return fmt.Errorf("ingress controller: %w", err)
 		}
-		elasticSearch := es.NewElasticsearch(ctx, provider, cfg)
-		logginNamespace, elasticSearchRelease, err := elasticSearch.CreateResources(ingressController)
+		logBackend, err := logbackend.NewLogBackend(ctx, provider, cfg)
 		if err != nil {
 			return err
 		}
-		kibana := kibanalogging.NewKibana(ctx, provider)
-		err = kibana.CreateResources(logginNamespace, elasticSearchRelease, hostname)
+		logginNamespace, logStore, err := logBackend.CreateResources(ingressController, hostname)
 		if err != nil {
-			return err
+			return fmt.Errorf("log backend: %w", err)
+		}
+		var archiveBucket *logarchive.Bucket
+		if archive := logarchive.NewLogArchive(ctx, provider, cfg); archive != nil {
+			archiveBucket, err = archive.CreateResources(logginNamespace)
+			if err != nil {
+				return fmt.Errorf("log archive: %w", err)
+			}
+		}
+		var buffer *kafkabuffer.Buffer
+		if kafka := kafkabuffer.NewKafkaBuffer(ctx, provider, cfg); kafka != nil {
+			buffer, err = kafka.CreateResources(logginNamespace, logStore)
+			if err != nil {
+				return fmt.Errorf("kafka buffer: %w", err)
+			}
 		}
 		fluentd := fluentdlogging.NewFluentD(ctx, provider, cfg)
-		fluentdRelease, err := fluentd.ConfigureResources(logginNamespace, elasticSearchRelease)
+		fluentdRelease, err := fluentd.ConfigureResources(logginNamespace, logStore, logBackend.Endpoint(), archiveBucket, buffer)
 		if err != nil {
-			return err
+			return fmt.Errorf("fluentd: %w", err)
+		}
+		if alerting := logalerting.NewLogAlerting(ctx, provider, cfg); alerting != nil {
+			_, err = alerting.CreateResources(logginNamespace, logBackend.Endpoint(), logStore)
+			if err != nil {
+				return fmt.Errorf("log alerting: %w", err)
+			}
+		}
+		if otlp := otlpingest.NewOTLPIngest(ctx, provider, cfg); otlp != nil {
+			_, err = otlp.CreateResources(logginNamespace, hostname, fluentdRelease)
+			if err != nil {
+				return fmt.Errorf("otlp ingest: %w", err)
+			}
+		}
+		eventsExporter := eventsexporter.NewEventsExporter(ctx, provider)
+		_, err = eventsExporter.CreateResources(logginNamespace, fluentdRelease)
+		if err != nil {
+			return fmt.Errorf("events exporter: %w", err)
 		}
 		redis := redis.NewRedis(ctx, provider)
 		databasesNamespace, redisService, err := redis.CreateResources(ingressController, fluentdRelease)
 		if err != nil {
-			return err
+			return fmt.Errorf("redis: %w", err)
 		}
 		mongoDB := mongodb.NewMongoDB(ctx, provider)
 		mongodbService, err := mongoDB.CreateResources(databasesNamespace, ingressController, fluentdRelease)
 		if err != nil {
-			return err
+			return fmt.Errorf("mongodb: %w", err)
 		}
 		application := app.NewApp(ctx, provider, cfg)
 		err = application.CreateResources(hostname, redisService, mongodbService, fluentdRelease)
 		if err != nil {
-			return err
+			return fmt.Errorf("app: %w", err)
 		}
 		return nil
 	})
 }
+
+// declareRoutes collects the Ingress rules of every enabled component, so they are
+// validated before anything is created.
+func declareRoutes(cfg *config.Config) (ingresscontroller.Routes, error) {
+	backendRoutes, err := logbackend.Routes(cfg)
+	if err != nil {
+		return nil, err
+	}
+	routes := ingresscontroller.Routes(backendRoutes)
+	routes = append(routes, otlpingest.Routes(cfg)...)
+	routes = append(routes, app.Routes()...)
+	return routes, nil
+}
@@ -1,6 +1,8 @@
 package metricsserver
 
 import (
+	"fmt"
+
 	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes"
 	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/yaml"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
@@ -26,6 +28,8 @@ func (m resource) CreateResources() (pulumi.Resource, error) {
 	resources, err := yaml.NewConfigFile(m.ctx, "metrics-server", &yaml.ConfigFileArgs{
 		File: "metrics-server/metrics-server.yaml",
 	}, pulumi.Provider(m.provider))
-
-	return resources, err
+	if err != nil {
+		return nil, fmt.Errorf("creating config file metrics-server: %w", err)
+	}
+	return resources, nil
 }
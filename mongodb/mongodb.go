@@ -1,6 +1,8 @@
 package mongodb
 
 import (
+	"fmt"
+
 	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes"
 	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/apps/v1"
 	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
@@ -64,7 +66,7 @@ func (m resource) CreateResources(databasesNamespace *corev1.Namespace, dependsO
 	}, pulumi.Provider(m.provider), pulumi.Parent(databasesNamespace), pulumi.DependsOn(dependsOn))
 
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("creating deployment mongodb: %w", err)
 	}
 
 	mongodbService, err = corev1.NewService(m.ctx, "mongodb", &corev1.ServiceArgs{
@@ -85,6 +87,8 @@ func (m resource) CreateResources(databasesNamespace *corev1.Namespace, dependsO
 			Type: pulumi.String("ClusterIP"),
 		},
 	}, pulumi.Provider(m.provider), pulumi.Parent(databasesNamespace), pulumi.DependsOn([]pulumi.Resource{deployment}))
-
-	return
+	if err != nil {
+		return nil, fmt.Errorf("creating service mongodb: %w", err)
+	}
+	return mongodbService, nil
 }
@@ -0,0 +1,171 @@
+package opensearchlogging
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/meta/v1"
+	networkingv1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/networking/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+	ingresscontroller "github.com/rodrigoafernandes/efk-cluster/ingress-controller"
+	"github.com/rodrigoafernandes/efk-cluster/readiness"
+)
+
+type OpenSearch interface {
+	CreateResources(parent pulumi.Resource, hostname pulumi.StringOutput) (*corev1.Namespace, pulumi.Resource, error)
+}
+
+type resource struct {
+	ctx      *pulumi.Context
+	provider *kubernetes.Provider
+	cfg      *config.Config
+}
+
+func NewOpenSearch(ctx *pulumi.Context, provider *kubernetes.Provider, cfg *config.Config) OpenSearch {
+	return resource{
+		ctx:      ctx,
+		provider: provider,
+		cfg:      cfg,
+	}
+}
+
+// Routes are the Ingress rules of OpenSearch Dashboards.
+func Routes() []ingresscontroller.Route {
+	return []ingresscontroller.Route{{Ingress: "opensearch-dashboards-ingress", Host: ingresscontroller.LoadBalancerHost, Path: "/dashboards"}}
+}
+
+func (o resource) CreateResources(parent pulumi.Resource, hostname pulumi.StringOutput) (*corev1.Namespace, pulumi.Resource, error) {
+	namespace, err := corev1.NewNamespace(o.ctx, "efk-namespace", &corev1.NamespaceArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Labels: pulumi.StringMap{
+				"name": pulumi.String("efk-logging"),
+			},
+			Name: pulumi.String("efk-logging"),
+		},
+	}, pulumi.Provider(o.provider), pulumi.DependsOn([]pulumi.Resource{parent}))
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating namespace efk-namespace: %w", err)
+	}
+	internalUsers, err := o.createSecurityConfig(namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+	release, err := helm.NewRelease(o.ctx, "opensearch", &helm.ReleaseArgs{
+		Name:      pulumi.String("opensearch"),
+		Namespace: namespace.Metadata.Name(),
+		Chart:     pulumi.String("opensearch"),
+		Version:   pulumi.String("2.9.1"),
+		RepositoryOpts: helm.RepositoryOptsArgs{
+			Repo: pulumi.String("https://opensearch-project.github.io/helm-charts"),
+		},
+		Values: pulumi.Map{
+			"clusterName": pulumi.String("opensearch-cluster"),
+			"nodeGroup":   pulumi.String("master"),
+			"persistence": pulumi.Map{
+				"storageClass": pulumi.String("linode-block-storage"),
+			},
+			"securityConfig": pulumi.Map{
+				"internalUsersSecret": internalUsers.Metadata.Name(),
+			},
+		},
+		Timeout: pulumi.Int(600),
+	}, pulumi.Provider(o.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{internalUsers}))
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating release opensearch: %w", err)
+	}
+	ready, err := readiness.NewJob(o.ctx, o.provider, namespace, readiness.Check{
+		Name:     "opensearch-ready",
+		URL:      "https://opensearch-cluster-master.efk-logging.svc.cluster.local:9200/_cluster/health?wait_for_status=yellow&timeout=5s",
+		Expect:   `"timed_out":false`,
+		User:     o.cfg.Get("opensearch_user"),
+		Password: o.cfg.GetSecret("opensearch_pwd"),
+		// The OpenSearch demo certificates are self-signed.
+		Insecure: true,
+	}, release)
+	if err != nil {
+		return nil, nil, err
+	}
+	account, err := o.createDashboardsAccount(namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+	dashboards, err := helm.NewRelease(o.ctx, "opensearch-dashboards", &helm.ReleaseArgs{
+		Name:      pulumi.String("opensearch-dashboards"),
+		Namespace: namespace.Metadata.Name(),
+		Chart:     pulumi.String("opensearch-dashboards"),
+		Version:   pulumi.String("2.7.0"),
+		RepositoryOpts: helm.RepositoryOptsArgs{
+			Repo: pulumi.String("https://opensearch-project.github.io/helm-charts"),
+		},
+		Values: pulumi.Map{
+			"opensearchHosts": pulumi.String("https://opensearch-cluster-master.efk-logging.svc.cluster.local:9200"),
+			"opensearchAccount": pulumi.Map{
+				"secret": account.Metadata.Name(),
+			},
+			// Served under /dashboards, next to the other applications of the load balancer hostname.
+			"extraEnvs": pulumi.MapArray{
+				pulumi.Map{
+					"name":  pulumi.String("SERVER_BASEPATH"),
+					"value": pulumi.String("/dashboards"),
+				},
+				pulumi.Map{
+					"name":  pulumi.String("SERVER_REWRITEBASEPATH"),
+					"value": pulumi.String("true"),
+				},
+			},
+		},
+		Timeout: pulumi.Int(300),
+	}, pulumi.Provider(o.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{ready, account}))
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating release opensearch-dashboards: %w", err)
+	}
+	dashboardsReady, err := readiness.NewJob(o.ctx, o.provider, namespace, readiness.Check{
+		Name:     "opensearch-dashboards-ready",
+		URL:      "http://opensearch-dashboards.efk-logging.svc.cluster.local:5601/dashboards/api/status",
+		Expect:   `"state":"green"`,
+		User:     o.cfg.Get("opensearch_user"),
+		Password: o.cfg.GetSecret("opensearch_pwd"),
+	}, dashboards)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = networkingv1.NewIngress(o.ctx, "opensearch-dashboards-ingress", &networkingv1.IngressArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("opensearch-dashboards-ingress"),
+			Namespace: namespace.Metadata.Name(),
+			Annotations: pulumi.StringMap{
+				"kubernetes.io/ingress.class": pulumi.String("nginx"),
+			},
+		},
+		Spec: networkingv1.IngressSpecArgs{
+			Rules: networkingv1.IngressRuleArray{
+				networkingv1.IngressRuleArgs{
+					Host: hostname,
+					Http: networkingv1.HTTPIngressRuleValueArgs{
+						Paths: networkingv1.HTTPIngressPathArray{
+							networkingv1.HTTPIngressPathArgs{
+								Path:     pulumi.String("/dashboards"),
+								PathType: pulumi.String("Prefix"),
+								Backend: networkingv1.IngressBackendArgs{
+									Service: networkingv1.IngressServiceBackendArgs{
+										Name: pulumi.String("opensearch-dashboards"),
+										Port: networkingv1.ServiceBackendPortArgs{
+											Number: pulumi.Int(5601),
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}, pulumi.Provider(o.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{dashboardsReady}))
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating ingress opensearch-dashboards-ingress: %w", err)
+	}
+	return namespace, ready, nil
+}
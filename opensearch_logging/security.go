@@ -0,0 +1,76 @@
+package opensearchlogging
+
+import (
+	"fmt"
+
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// internalUsers replaces the demo users of the security plugin with the "opensearch_user"
+// admin, so the credentials fluentd, the readiness checks and Dashboards use are the ones
+// the cluster is seeded with.
+const internalUsers = `_meta:
+  type: "internalusers"
+  config_version: 2
+%q:
+  hash: %q
+  reserved: true
+  backend_roles:
+  - "admin"
+  description: "Admin user, from opensearch_user"
+`
+
+// createSecurityConfig stores internal_users.yml for the securityConfig.internalUsersSecret
+// value of the chart. The security index is only seeded from it on the first start of the
+// cluster: changing "opensearch_pwd" afterwards needs securityadmin.sh, so later changes
+// of the secret, which bcrypt salts anew on every run, are ignored.
+func (o resource) createSecurityConfig(namespace *corev1.Namespace) (*corev1.Secret, error) {
+	user := o.cfg.Get("opensearch_user")
+	if user == "" {
+		return nil, fmt.Errorf("opensearch_user is required, it is the admin user of the cluster")
+	}
+	users := o.cfg.GetSecret("opensearch_pwd").ApplyT(func(password string) (string, error) {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+		if err != nil {
+			return "", fmt.Errorf("hashing opensearch_pwd: %w", err)
+		}
+		return fmt.Sprintf(internalUsers, user, hash), nil
+	}).(pulumi.StringOutput)
+	secret, err := corev1.NewSecret(o.ctx, "opensearch-internal-users", &corev1.SecretArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("opensearch-internal-users"),
+			Namespace: namespace.Metadata.Name(),
+		},
+		Type: pulumi.String("Opaque"),
+		StringData: pulumi.StringMap{
+			"internal_users.yml": users,
+		},
+	}, pulumi.Provider(o.provider), pulumi.Parent(namespace), pulumi.IgnoreChanges([]string{"stringData"}))
+	if err != nil {
+		return nil, fmt.Errorf("creating secret opensearch-internal-users: %w", err)
+	}
+	return secret, nil
+}
+
+// createDashboardsAccount holds the credentials Dashboards connects to the cluster with,
+// read by the opensearchAccount.secret value of its chart.
+func (o resource) createDashboardsAccount(namespace *corev1.Namespace) (*corev1.Secret, error) {
+	secret, err := corev1.NewSecret(o.ctx, "opensearch-dashboards-account", &corev1.SecretArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("opensearch-dashboards-account"),
+			Namespace: namespace.Metadata.Name(),
+		},
+		Type: pulumi.String("Opaque"),
+		StringData: pulumi.StringMap{
+			"username": pulumi.String(o.cfg.Get("opensearch_user")),
+			"password": o.cfg.GetSecret("opensearch_pwd"),
+		},
+	}, pulumi.Provider(o.provider), pulumi.Parent(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("creating secret opensearch-dashboards-account: %w", err)
+	}
+	return secret, nil
+}
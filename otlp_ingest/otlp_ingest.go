@@ -0,0 +1,330 @@
+package otlpingest
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+
+	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes"
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/apps/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/meta/v1"
+	networkingv1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/networking/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+	ingresscontroller "github.com/rodrigoafernandes/efk-cluster/ingress-controller"
+)
+
+const (
+	collectorConfig = "otlp_ingest/collector.yaml"
+	// collectorImage is the first contrib release whose distribution ships the fluentforward
+	// exporter; with an image lacking it the collector exits on an unknown exporter type.
+	collectorImage = "otel/opentelemetry-collector-contrib:0.88.0"
+	// fluentdForward is the forward input of the Fluentd aggregators the collector exports to.
+	fluentdForward  = "fluentd-aggregator.efk-logging.svc.cluster.local:24224"
+	grpcLogsService = "/opentelemetry.proto.collector.logs.v1.LogsService"
+)
+
+// OTLPIngest receives logs pushed over OTLP/HTTP and OTLP/gRPC by services that do not
+// log to stdout, or run outside the cluster, and hands them to the Fluentd aggregators.
+// The collector is published on the load balancer only with a certificate, from
+// "otlp_tls_cert" and "otlp_tls_key": clients authenticate with basic auth, which must not
+// travel in clear text. Without one it is reachable inside the cluster only, at
+// otel-collector.efk-logging.svc.cluster.local on ports 4317 (gRPC) and 4318 (HTTP).
+type OTLPIngest interface {
+	CreateResources(namespace *corev1.Namespace, hostname pulumi.StringOutput, dependsOn ...pulumi.Resource) (pulumi.Resource, error)
+}
+
+type resource struct {
+	ctx      *pulumi.Context
+	provider *kubernetes.Provider
+	cfg      *config.Config
+}
+
+// NewOTLPIngest returns the collector when "otlp_ingest" is enabled, nil otherwise.
+func NewOTLPIngest(ctx *pulumi.Context, provider *kubernetes.Provider, cfg *config.Config) OTLPIngest {
+	if !cfg.GetBool("otlp_ingest") {
+		return nil
+	}
+	return resource{
+		ctx:      ctx,
+		provider: provider,
+		cfg:      cfg,
+	}
+}
+
+// Routes are the Ingress rules of the collector, published only with a certificate.
+func Routes(cfg *config.Config) []ingresscontroller.Route {
+	if !cfg.GetBool("otlp_ingest") || cfg.Get("otlp_tls_cert") == "" {
+		return nil
+	}
+	return []ingresscontroller.Route{
+		{Ingress: "otlp-http-ingress", Host: ingresscontroller.LoadBalancerHost, Path: "/v1/logs"},
+		{Ingress: "otlp-grpc-ingress", Host: ingresscontroller.LoadBalancerHost, Path: grpcLogsService},
+	}
+}
+
+func (o resource) CreateResources(namespace *corev1.Namespace, hostname pulumi.StringOutput, dependsOn ...pulumi.Resource) (pulumi.Resource, error) {
+	collectorConf, err := os.ReadFile(collectorConfig)
+	if err != nil {
+		return nil, err
+	}
+	collectorLabels := pulumi.StringMap{
+		"app": pulumi.String("otel-collector"),
+	}
+	// Clients authenticate with basic auth, checked by the collector itself so both
+	// protocols share the same credentials.
+	credentials, err := corev1.NewSecret(o.ctx, "otel-collector-auth", &corev1.SecretArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("otel-collector-auth"),
+			Namespace: namespace.Metadata.Name(),
+			Labels:    collectorLabels,
+		},
+		StringData: pulumi.StringMap{
+			"OTLP_USER":     pulumi.String(o.cfg.Get("otlp_user")),
+			"OTLP_PASSWORD": o.cfg.GetSecret("otlp_pwd"),
+		},
+	}, pulumi.Provider(o.provider), pulumi.Parent(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("creating secret otel-collector-auth: %w", err)
+	}
+	configMap, err := corev1.NewConfigMap(o.ctx, "otel-collector-cm", &corev1.ConfigMapArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("otel-collector-cm"),
+			Namespace: namespace.Metadata.Name(),
+			Labels:    collectorLabels,
+		},
+		Data: pulumi.StringMap{
+			"collector.yaml": pulumi.String(collectorConf),
+		},
+	}, pulumi.Provider(o.provider), pulumi.Parent(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("creating config map otel-collector-cm: %w", err)
+	}
+	deployment, err := appsv1.NewDeployment(o.ctx, "otel-collector", &appsv1.DeploymentArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("otel-collector"),
+			Namespace: namespace.Metadata.Name(),
+			Labels:    collectorLabels,
+		},
+		Spec: appsv1.DeploymentSpecArgs{
+			Selector: &metav1.LabelSelectorArgs{
+				MatchLabels: collectorLabels,
+			},
+			Replicas: pulumi.Int(2),
+			Template: &corev1.PodTemplateSpecArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					Labels: collectorLabels,
+					Annotations: pulumi.StringMap{
+						"checksum/config": pulumi.String(fmt.Sprintf("%x", sha256.Sum256(collectorConf))),
+					},
+				},
+				Spec: &corev1.PodSpecArgs{
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:            pulumi.String("otel-collector"),
+							Image:           pulumi.String(collectorImage),
+							ImagePullPolicy: pulumi.String("IfNotPresent"),
+							Args: pulumi.StringArray{
+								pulumi.String("--config=/etc/otel-collector/collector.yaml"),
+							},
+							Env: corev1.EnvVarArray{
+								corev1.EnvVarArgs{
+									Name:  pulumi.String("FLUENTD_FORWARD_ENDPOINT"),
+									Value: pulumi.String(fluentdForward),
+								},
+							},
+							EnvFrom: corev1.EnvFromSourceArray{
+								corev1.EnvFromSourceArgs{
+									SecretRef: corev1.SecretEnvSourceArgs{
+										Name: credentials.Metadata.Name(),
+									},
+								},
+							},
+							Ports: corev1.ContainerPortArray{
+								corev1.ContainerPortArgs{
+									Name:          pulumi.String("otlp-grpc"),
+									ContainerPort: pulumi.Int(4317),
+								},
+								corev1.ContainerPortArgs{
+									Name:          pulumi.String("otlp-http"),
+									ContainerPort: pulumi.Int(4318),
+								},
+								corev1.ContainerPortArgs{
+									Name:          pulumi.String("health"),
+									ContainerPort: pulumi.Int(13133),
+								},
+							},
+							ReadinessProbe: corev1.ProbeArgs{
+								HttpGet: corev1.HTTPGetActionArgs{
+									Path: pulumi.String("/"),
+									Port: pulumi.Int(13133),
+								},
+							},
+							LivenessProbe: corev1.ProbeArgs{
+								HttpGet: corev1.HTTPGetActionArgs{
+									Path: pulumi.String("/"),
+									Port: pulumi.Int(13133),
+								},
+							},
+							Resources: &corev1.ResourceRequirementsArgs{
+								Requests: pulumi.StringMap{
+									"memory": pulumi.String("128Mi"),
+									"cpu":    pulumi.String("100m"),
+								},
+								Limits: pulumi.StringMap{
+									"memory": pulumi.String("512Mi"),
+									"cpu":    pulumi.String("500m"),
+								},
+							},
+							VolumeMounts: corev1.VolumeMountArray{
+								corev1.VolumeMountArgs{
+									Name:      pulumi.String("config-volume"),
+									MountPath: pulumi.String("/etc/otel-collector"),
+								},
+							},
+						},
+					},
+					Volumes: corev1.VolumeArray{
+						corev1.VolumeArgs{
+							Name: pulumi.String("config-volume"),
+							ConfigMap: corev1.ConfigMapVolumeSourceArgs{
+								Name: configMap.Metadata.Name(),
+							},
+						},
+					},
+				},
+			},
+		},
+	}, pulumi.Provider(o.provider), pulumi.Parent(namespace), pulumi.DependsOn(append(dependsOn, credentials, configMap)))
+	if err != nil {
+		return nil, fmt.Errorf("creating deployment otel-collector: %w", err)
+	}
+	svc, err := corev1.NewService(o.ctx, "otel-collector", &corev1.ServiceArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("otel-collector"),
+			Namespace: namespace.Metadata.Name(),
+			Labels:    collectorLabels,
+		},
+		Spec: &corev1.ServiceSpecArgs{
+			Selector: collectorLabels,
+			Ports: corev1.ServicePortArray{
+				corev1.ServicePortArgs{
+					Name:       pulumi.String("otlp-grpc"),
+					Port:       pulumi.Int(4317),
+					TargetPort: pulumi.Int(4317),
+				},
+				corev1.ServicePortArgs{
+					Name:       pulumi.String("otlp-http"),
+					Port:       pulumi.Int(4318),
+					TargetPort: pulumi.Int(4318),
+				},
+			},
+		},
+	}, pulumi.Provider(o.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{deployment}))
+	if err != nil {
+		return nil, fmt.Errorf("creating service otel-collector: %w", err)
+	}
+	certificate, err := o.createCertificate(namespace)
+	if err != nil || certificate == nil {
+		return deployment, err
+	}
+	tls := networkingv1.IngressTLSArray{
+		networkingv1.IngressTLSArgs{
+			Hosts:      pulumi.StringArray{hostname},
+			SecretName: certificate.Metadata.Name(),
+		},
+	}
+	// OTLP/HTTP clients post to /v1/logs on the shared hostname.
+	_, err = networkingv1.NewIngress(o.ctx, "otlp-http-ingress", &networkingv1.IngressArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("otlp-http-ingress"),
+			Namespace: namespace.Metadata.Name(),
+			Annotations: pulumi.StringMap{
+				"kubernetes.io/ingress.class":                 pulumi.String("nginx"),
+				"nginx.ingress.kubernetes.io/proxy-body-size": pulumi.String("16m"),
+			},
+		},
+		Spec: networkingv1.IngressSpecArgs{
+			Tls: tls,
+			Rules: networkingv1.IngressRuleArray{
+				ingressRule(hostname, "/v1/logs", svc, 4318),
+			},
+		},
+	}, pulumi.Provider(o.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{svc, certificate}))
+	if err != nil {
+		return nil, fmt.Errorf("creating ingress otlp-http-ingress: %w", err)
+	}
+	// nginx only speaks HTTP/2 on its TLS listener, so gRPC clients connect on port 443.
+	_, err = networkingv1.NewIngress(o.ctx, "otlp-grpc-ingress", &networkingv1.IngressArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("otlp-grpc-ingress"),
+			Namespace: namespace.Metadata.Name(),
+			Annotations: pulumi.StringMap{
+				"kubernetes.io/ingress.class":                  pulumi.String("nginx"),
+				"nginx.ingress.kubernetes.io/backend-protocol": pulumi.String("GRPC"),
+				"nginx.ingress.kubernetes.io/proxy-body-size":  pulumi.String("16m"),
+			},
+		},
+		Spec: networkingv1.IngressSpecArgs{
+			Tls: tls,
+			Rules: networkingv1.IngressRuleArray{
+				ingressRule(hostname, grpcLogsService, svc, 4317),
+			},
+		},
+	}, pulumi.Provider(o.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{svc, certificate}))
+	if err != nil {
+		return nil, fmt.Errorf("creating ingress otlp-grpc-ingress: %w", err)
+	}
+	return deployment, nil
+}
+
+// createCertificate stores "otlp_tls_cert" and "otlp_tls_key", PEM encoded, for the
+// ingresses. It returns nil when no certificate is configured.
+func (o resource) createCertificate(namespace *corev1.Namespace) (*corev1.Secret, error) {
+	cert := o.cfg.Get("otlp_tls_cert")
+	if cert == "" {
+		return nil, nil
+	}
+	key, err := o.cfg.TrySecret("otlp_tls_key")
+	if err != nil {
+		return nil, fmt.Errorf("otlp_tls_cert needs otlp_tls_key: %w", err)
+	}
+	secret, err := corev1.NewSecret(o.ctx, "otel-collector-tls", &corev1.SecretArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("otel-collector-tls"),
+			Namespace: namespace.Metadata.Name(),
+		},
+		Type: pulumi.String("kubernetes.io/tls"),
+		StringData: pulumi.StringMap{
+			"tls.crt": pulumi.String(cert),
+			"tls.key": key,
+		},
+	}, pulumi.Provider(o.provider), pulumi.Parent(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("creating secret otel-collector-tls: %w", err)
+	}
+	return secret, nil
+}
+
+func ingressRule(hostname pulumi.StringOutput, path string, svc *corev1.Service, port int) networkingv1.IngressRuleArgs {
+	return networkingv1.IngressRuleArgs{
+		Host: hostname,
+		Http: networkingv1.HTTPIngressRuleValueArgs{
+			Paths: networkingv1.HTTPIngressPathArray{
+				networkingv1.HTTPIngressPathArgs{
+					Path:     pulumi.String(path),
+					PathType: pulumi.String("Prefix"),
+					Backend: networkingv1.IngressBackendArgs{
+						Service: networkingv1.IngressServiceBackendArgs{
+							Name: svc.Metadata.Name().Elem().ToStringOutput(),
+							Port: networkingv1.ServiceBackendPortArgs{
+								Number: pulumi.Int(port),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
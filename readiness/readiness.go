@@ -0,0 +1,112 @@
+package readiness
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes"
+	batchv1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/batch/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const defaultTimeoutSeconds = 600
+
+// Check is an HTTP endpoint polled until it answers with a 2xx status and a body
+// containing Expect. An empty Expect accepts any successful answer.
+type Check struct {
+	// Name names the Job, e.g. elasticsearch-ready.
+	Name     string
+	URL      string
+	Expect   string
+	User     string
+	Password pulumi.StringInput
+	// Insecure skips the verification of the server certificate, for self-signed ones.
+	Insecure bool
+	// TimeoutSeconds bounds the wait, 10 minutes when zero.
+	TimeoutSeconds int
+}
+
+// NewJob runs check in a Job. Pulumi waits for Jobs to complete, so resources depending
+// on the returned Job are only created once the endpoint is healthy, and `pulumi up`
+// fails with the Job as the culprit when it never gets there.
+func NewJob(ctx *pulumi.Context, provider *kubernetes.Provider, namespace *corev1.Namespace, check Check, dependsOn ...pulumi.Resource) (pulumi.Resource, error) {
+	timeout := check.TimeoutSeconds
+	if timeout <= 0 {
+		timeout = defaultTimeoutSeconds
+	}
+	env := corev1.EnvVarArray{
+		corev1.EnvVarArgs{Name: pulumi.String("CHECK_NAME"), Value: pulumi.String(check.Name)},
+		corev1.EnvVarArgs{Name: pulumi.String("CHECK_URL"), Value: pulumi.String(check.URL)},
+		corev1.EnvVarArgs{Name: pulumi.String("CHECK_EXPECT"), Value: pulumi.String(check.Expect)},
+	}
+	var envFrom corev1.EnvFromSourceArray
+	if check.User != "" {
+		credentials, err := corev1.NewSecret(ctx, check.Name+"-credentials", &corev1.SecretArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String(check.Name + "-credentials"),
+				Namespace: namespace.Metadata.Name(),
+			},
+			Type: pulumi.String("Opaque"),
+			StringData: pulumi.StringMap{
+				"CHECK_USER":     pulumi.String(check.User),
+				"CHECK_PASSWORD": check.Password,
+			},
+		}, pulumi.Provider(provider), pulumi.Parent(namespace))
+		if err != nil {
+			return nil, fmt.Errorf("creating secret %s-credentials: %w", check.Name, err)
+		}
+		envFrom = corev1.EnvFromSourceArray{
+			corev1.EnvFromSourceArgs{
+				SecretRef: corev1.SecretEnvSourceArgs{
+					Name: credentials.Metadata.Name(),
+				},
+			},
+		}
+		dependsOn = append(dependsOn, credentials)
+	}
+	flags := "-sf"
+	if check.Insecure {
+		flags = "-sfk"
+	}
+	// The check is passed through the environment, so no value is ever parsed by the shell.
+	script := fmt.Sprintf(`get() {
+  if [ -n "$CHECK_USER" ]; then
+    curl %[1]s -u "$CHECK_USER:$CHECK_PASSWORD" "$CHECK_URL"
+  else
+    curl %[1]s "$CHECK_URL"
+  fi
+}
+until get | grep -qF "$CHECK_EXPECT"; do echo "waiting for $CHECK_NAME"; sleep 5; done`, flags)
+	job, err := batchv1.NewJob(ctx, check.Name, &batchv1.JobArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Namespace: namespace.Metadata.Name(),
+		},
+		Spec: batchv1.JobSpecArgs{
+			BackoffLimit:          pulumi.Int(0),
+			ActiveDeadlineSeconds: pulumi.Int(timeout),
+			Template: corev1.PodTemplateSpecArgs{
+				Spec: corev1.PodSpecArgs{
+					RestartPolicy: pulumi.String("Never"),
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:  pulumi.String(check.Name),
+							Image: pulumi.String("docker.io/curlimages/curl:7.87.0"),
+							Command: pulumi.StringArray{
+								pulumi.String("sh"),
+								pulumi.String("-c"),
+								pulumi.String(script),
+							},
+							Env:     env,
+							EnvFrom: envFrom,
+						},
+					},
+				},
+			},
+		},
+	}, pulumi.Provider(provider), pulumi.Parent(namespace), pulumi.DependsOn(dependsOn))
+	if err != nil {
+		return nil, fmt.Errorf("creating job %s: %w", check.Name, err)
+	}
+	return job, nil
+}
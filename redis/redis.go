@@ -1,6 +1,8 @@
 package redis
 
 import (
+	"fmt"
+
 	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes"
 	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/apps/v1"
 	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
@@ -35,7 +37,7 @@ func (r resource) CreateResources(parents ...pulumi.Resource) (namespace *corev1
 	}, pulumi.Provider(r.provider), pulumi.DependsOn(parents))
 
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, fmt.Errorf("creating namespace databases-namespace: %w", err)
 	}
 
 	redisAppLabels := pulumi.StringMap{
@@ -77,7 +79,7 @@ func (r resource) CreateResources(parents ...pulumi.Resource) (namespace *corev1
 	}, pulumi.Provider(r.provider), pulumi.Parent(namespace))
 
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, fmt.Errorf("creating deployment redis: %w", err)
 	}
 
 	redisService, err = corev1.NewService(r.ctx, "redis", &corev1.ServiceArgs{
@@ -96,6 +98,8 @@ func (r resource) CreateResources(parents ...pulumi.Resource) (namespace *corev1
 			Type: pulumi.String("ClusterIP"),
 		},
 	}, pulumi.Provider(r.provider), pulumi.Parent(namespace), pulumi.DependsOn([]pulumi.Resource{deployment}))
-
-	return
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating service redis: %w", err)
+	}
+	return namespace, redisService, nil
 }
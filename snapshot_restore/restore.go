@@ -0,0 +1,126 @@
+package snapshotrestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Options tells where the cluster is and which repository holds the snapshots.
+type Options struct {
+	URL        string
+	User       string
+	Password   string
+	Repository string
+	Client     *http.Client
+}
+
+// Snapshot is a snapshot of the repository as listed by the cluster.
+type Snapshot struct {
+	Name      string    `json:"snapshot"`
+	State     string    `json:"state"`
+	Indices   []string  `json:"indices"`
+	StartTime time.Time `json:"start_time"`
+}
+
+// Request selects what a restore brings back. Indices that exist in the cluster cannot
+// be restored over, so they are restored under RenamePrefix unless it is empty, in which
+// case the existing indices have to be closed or deleted first.
+type Request struct {
+	Snapshot     string
+	Indices      string
+	RenamePrefix string
+}
+
+// List returns the snapshots of the repository, the most recent first.
+func List(ctx context.Context, opts Options) ([]Snapshot, error) {
+	var response struct {
+		Snapshots []Snapshot `json:"snapshots"`
+	}
+	path := fmt.Sprintf("/_snapshot/%s/_all?sort=start_time&order=desc", url.PathEscape(opts.Repository))
+	if err := call(ctx, opts, http.MethodGet, path, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Snapshots, nil
+}
+
+// Latest returns the most recent snapshot that completed successfully.
+func Latest(ctx context.Context, opts Options) (Snapshot, error) {
+	snapshots, err := List(ctx, opts)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.State == "SUCCESS" {
+			return snapshot, nil
+		}
+	}
+	return Snapshot{}, fmt.Errorf("repository %s has no successful snapshot", opts.Repository)
+}
+
+// Restore restores the indices of req from its snapshot and waits until their primary
+// shards are recovered. The cluster state is never restored, so users, roles and
+// templates stay as they are.
+func Restore(ctx context.Context, opts Options, req Request) error {
+	body := map[string]interface{}{
+		"indices":              req.Indices,
+		"include_global_state": false,
+		"include_aliases":      false,
+	}
+	if req.RenamePrefix != "" {
+		body["rename_pattern"] = "(.+)"
+		body["rename_replacement"] = req.RenamePrefix + "$1"
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("/_snapshot/%s/%s/_restore?wait_for_completion=true", url.PathEscape(opts.Repository), url.PathEscape(req.Snapshot))
+	var response struct {
+		Snapshot struct {
+			Indices []string `json:"indices"`
+			Shards  struct {
+				Total      int `json:"total"`
+				Failed     int `json:"failed"`
+				Successful int `json:"successful"`
+			} `json:"shards"`
+		} `json:"snapshot"`
+	}
+	if err := call(ctx, opts, http.MethodPost, path, payload, &response); err != nil {
+		return err
+	}
+	if failed := response.Snapshot.Shards.Failed; failed > 0 {
+		return fmt.Errorf("%d of %d shards failed to restore", failed, response.Snapshot.Shards.Total)
+	}
+	return nil
+}
+
+func call(ctx context.Context, opts Options, method, path string, body []byte, response interface{}) error {
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	request, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(opts.URL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if opts.User != "" {
+		request.SetBasicAuth(opts.User, opts.Password)
+	}
+	resp, err := opts.Client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s returned %s: %s", method, path, resp.Status, message)
+	}
+	return json.NewDecoder(resp.Body).Decode(response)
+}